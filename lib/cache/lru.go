@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"container/list"
+	"github.com/safecility/go/lib"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	uid     string
+	meta    *lib.DeviceMeta
+	expires time.Time
+}
+
+// lru is a fixed size least recently used map of DeviceUID to meta with per entry expiry
+type lru struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+func (l *lru) get(uid string) (*lib.DeviceMeta, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.entries[uid]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if l.now().After(e.expires) {
+		l.order.Remove(el)
+		delete(l.entries, uid)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return e.meta, true
+}
+
+func (l *lru) add(uid string, meta *lib.DeviceMeta, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := &lruEntry{uid: uid, meta: meta, expires: l.now().Add(ttl)}
+	if el, ok := l.entries[uid]; ok {
+		el.Value = e
+		l.order.MoveToFront(el)
+		return
+	}
+	l.entries[uid] = l.order.PushFront(e)
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).uid)
+	}
+}
+
+func (l *lru) remove(uid string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.entries[uid]; ok {
+		l.order.Remove(el)
+		delete(l.entries, uid)
+	}
+}
+
+func (l *lru) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"github.com/safecility/go/lib"
+	"golang.org/x/sync/singleflight"
+	"sync"
+	"time"
+)
+
+// DeviceSource is the backing store behind a MetaCache, a lib.DeviceRegistry satisfies it
+type DeviceSource interface {
+	GetDevice(ctx context.Context, uid string) (*lib.Device, error)
+}
+
+// notFound marks a negative entry in redis
+const notFound = "-"
+
+// MetaCacheConfig sets the size and lifetimes of the cache layers, zero values take the defaults
+type MetaCacheConfig struct {
+	// Size is the number of entries kept in the local LRU
+	Size int `json:"size"`
+	// LocalTTL bounds how stale a local entry can be when another instance changes a device
+	LocalTTL time.Duration `json:"localTTL"`
+	RedisTTL time.Duration `json:"redisTTL"`
+	// NegativeTTL is how long an unknown DeviceUID is remembered in both layers
+	NegativeTTL time.Duration `json:"negativeTTL"`
+	KeyPrefix   string        `json:"keyPrefix"`
+	// InvalidationChannel is the redis channel Invalidate publishes on and Listen subscribes to
+	InvalidationChannel string `json:"invalidationChannel"`
+	// LoadTimeout bounds a shared lookup, it runs apart from any one caller's context so a caller giving up doesn't
+	// fail the others waiting on it
+	LoadTimeout time.Duration `json:"loadTimeout"`
+}
+
+func (c MetaCacheConfig) withDefaults() MetaCacheConfig {
+	if c.Size <= 0 {
+		c.Size = 10000
+	}
+	if c.LocalTTL <= 0 {
+		c.LocalTTL = time.Minute
+	}
+	if c.RedisTTL <= 0 {
+		c.RedisTTL = time.Hour
+	}
+	if c.NegativeTTL <= 0 {
+		c.NegativeTTL = 30 * time.Second
+	}
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = "device-meta:"
+	}
+	if c.InvalidationChannel == "" {
+		c.InvalidationChannel = c.KeyPrefix + "invalidate"
+	}
+	if c.LoadTimeout <= 0 {
+		c.LoadTimeout = 10 * time.Second
+	}
+	return c
+}
+
+// MetaCache resolves a DeviceUID to its lib.DeviceMeta through a local LRU, then redis, then the DeviceSource.
+// Concurrent misses for a DeviceUID share one lookup and unknown devices are cached for NegativeTTL.
+// Redis is optional and redis errors fall through to the source so the cache is never the reason a lookup fails.
+type MetaCache struct {
+	config MetaCacheConfig
+	local  *lru
+	redis  *redis.Client
+	source DeviceSource
+	group  singleflight.Group
+
+	// mu orders local stores against Invalidate, flights are the loads in progress per DeviceUID
+	mu      sync.Mutex
+	flights map[string]map[*flight]struct{}
+}
+
+// flight is one load, marked stale when its DeviceUID is invalidated so it doesn't cache what it read
+type flight struct {
+	stale bool
+}
+
+// NewMetaCache builds the cache, rdb may be nil for a local only cache - typically rdb comes from setup.RedisConfig.NewClient
+func NewMetaCache(source DeviceSource, rdb *redis.Client, config MetaCacheConfig) *MetaCache {
+	config = config.withDefaults()
+	return &MetaCache{
+		config:  config,
+		local:   newLRU(config.Size),
+		redis:   rdb,
+		source:  source,
+		flights: make(map[string]map[*flight]struct{}),
+	}
+}
+
+// Get returns the meta for uid or an error wrapping lib.ErrDeviceNotFound.
+// The returned meta is shared with the cache and must not be modified.
+func (c *MetaCache) Get(ctx context.Context, uid string) (*lib.DeviceMeta, error) {
+	if meta, ok := c.local.get(uid); ok {
+		return found(uid, meta)
+	}
+	ch := c.group.DoChan(uid, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.config.LoadTimeout)
+		defer cancel()
+		return c.load(loadCtx, uid)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return found(uid, r.Val.(*lib.DeviceMeta))
+	}
+}
+
+func found(uid string, meta *lib.DeviceMeta) (*lib.DeviceMeta, error) {
+	if meta == nil {
+		return nil, fmt.Errorf("%w: %s", lib.ErrDeviceNotFound, uid)
+	}
+	return meta, nil
+}
+
+// load reads through redis to the source, a nil meta is a cached not found
+func (c *MetaCache) load(ctx context.Context, uid string) (*lib.DeviceMeta, error) {
+	f := c.begin(uid)
+	defer c.end(uid, f)
+
+	if meta, ok := c.fromRedis(ctx, uid); ok {
+		c.store(f, uid, meta, c.config.LocalTTL)
+		return meta, nil
+	}
+
+	d, err := c.source.GetDevice(ctx, uid)
+	if errors.Is(err, lib.ErrDeviceNotFound) {
+		c.store(f, uid, nil, c.config.NegativeTTL)
+		c.toRedis(ctx, f, uid, nil)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load device %s: %w", uid, err)
+	}
+	meta := d.DeviceMeta
+	if meta == nil {
+		meta = &lib.DeviceMeta{}
+	}
+	c.store(f, uid, meta, c.config.LocalTTL)
+	c.toRedis(ctx, f, uid, meta)
+	return meta, nil
+}
+
+func (c *MetaCache) begin(uid string) *flight {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := &flight{}
+	if c.flights[uid] == nil {
+		c.flights[uid] = make(map[*flight]struct{})
+	}
+	c.flights[uid][f] = struct{}{}
+	return f
+}
+
+func (c *MetaCache) end(uid string, f *flight) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.flights[uid], f)
+	if len(c.flights[uid]) == 0 {
+		delete(c.flights, uid)
+	}
+}
+
+func (c *MetaCache) isStale(f *flight) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return f.stale
+}
+
+// store adds to the local layer unless uid was invalidated since the load began
+func (c *MetaCache) store(f *flight, uid string, meta *lib.DeviceMeta, ttl time.Duration) {
+	if meta == nil && ttl > c.config.LocalTTL {
+		ttl = c.config.LocalTTL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !f.stale {
+		c.local.add(uid, meta, ttl)
+	}
+}
+
+func (c *MetaCache) key(uid string) string {
+	return c.config.KeyPrefix + uid
+}
+
+func (c *MetaCache) fromRedis(ctx context.Context, uid string) (*lib.DeviceMeta, bool) {
+	if c.redis == nil {
+		return nil, false
+	}
+	v, err := c.redis.Get(ctx, c.key(uid)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("deviceUID", uid).Msg("could not read device meta from redis")
+		return nil, false
+	}
+	if v == notFound {
+		return nil, true
+	}
+	meta := &lib.DeviceMeta{}
+	if err = json.Unmarshal([]byte(v), meta); err != nil {
+		log.Warn().Err(err).Str("deviceUID", uid).Msg("bad device meta in redis")
+		return nil, false
+	}
+	return meta, true
+}
+
+func (c *MetaCache) toRedis(ctx context.Context, f *flight, uid string, meta *lib.DeviceMeta) {
+	if c.redis == nil || c.isStale(f) {
+		return
+	}
+	v, ttl := notFound, c.config.NegativeTTL
+	if meta != nil {
+		b, err := json.Marshal(meta)
+		if err != nil {
+			log.Warn().Err(err).Str("deviceUID", uid).Msg("could not marshal device meta")
+			return
+		}
+		v, ttl = string(b), c.config.RedisTTL
+	}
+	if err := c.redis.Set(ctx, c.key(uid), v, ttl).Err(); err != nil {
+		log.Warn().Err(err).Str("deviceUID", uid).Msg("could not write device meta to redis")
+		return
+	}
+	// an Invalidate that deleted the key before the Set marked the flight stale first, so take the write back
+	if c.isStale(f) {
+		if err := c.redis.Del(ctx, c.key(uid)).Err(); err != nil {
+			log.Warn().Err(err).Str("deviceUID", uid).Msg("could not remove stale device meta from redis")
+		}
+	}
+}
+
+// Invalidate drops uid from both layers and tells other instances listening on the InvalidationChannel to drop it.
+// Call it after the device changes in the source.
+func (c *MetaCache) Invalidate(ctx context.Context, uid string) error {
+	c.drop(uid)
+	c.group.Forget(uid)
+	if c.redis == nil {
+		return nil
+	}
+	if err := c.redis.Del(ctx, c.key(uid)).Err(); err != nil {
+		return fmt.Errorf("could not delete device meta %s: %w", uid, err)
+	}
+	if err := c.redis.Publish(ctx, c.config.InvalidationChannel, uid).Err(); err != nil {
+		return fmt.Errorf("could not publish invalidation of %s: %w", uid, err)
+	}
+	return nil
+}
+
+// Listen drops local entries invalidated by other instances until ctx is done
+func (c *MetaCache) Listen(ctx context.Context) error {
+	if c.redis == nil {
+		return fmt.Errorf("invalidation needs a redis client")
+	}
+	sub := c.redis.Subscribe(ctx, c.config.InvalidationChannel)
+	defer func() {
+		_ = sub.Close()
+	}()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("could not subscribe to %s: %w", c.config.InvalidationChannel, err)
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			c.drop(m.Payload)
+		}
+	}
+}
+
+// drop removes uid from the local layer and stops loads already under way from caching it again
+func (c *MetaCache) drop(uid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for f := range c.flights[uid] {
+		f.stale = true
+	}
+	c.local.remove(uid)
+}
@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/safecility/go/lib"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingSource struct {
+	lib.DeviceRegistry
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *countingSource) GetDevice(ctx context.Context, uid string) (*lib.Device, error) {
+	s.calls.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.DeviceRegistry.GetDevice(ctx, uid)
+}
+
+func newTestSource(t *testing.T) *countingSource {
+	r := lib.NewMemoryDeviceRegistry()
+	err := r.UpsertDevice(context.Background(), &lib.Device{
+		DeviceUID:  "a",
+		DeviceMeta: &lib.DeviceMeta{DeviceTag: "cooler-4b", DeviceType: lib.Power, CompanyUID: "c1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &countingSource{DeviceRegistry: r}
+}
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = rdb.Close()
+	})
+	return mr, rdb
+}
+
+func TestMetaCache_Get(t *testing.T) {
+	ctx := context.Background()
+	source := newTestSource(t)
+	mr, rdb := newTestRedis(t)
+	c := NewMetaCache(source, rdb, MetaCacheConfig{})
+
+	tests := []struct {
+		name      string
+		uid       string
+		wantTag   string
+		wantErr   error
+		wantCalls int32
+	}{
+		{name: "miss loads source", uid: "a", wantTag: "cooler-4b", wantCalls: 1},
+		{name: "hit", uid: "a", wantTag: "cooler-4b", wantCalls: 1},
+		{name: "unknown", uid: "x", wantErr: lib.ErrDeviceNotFound, wantCalls: 2},
+		{name: "negative hit", uid: "x", wantErr: lib.ErrDeviceNotFound, wantCalls: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := c.Get(ctx, tt.uid)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && meta.DeviceTag != tt.wantTag {
+				t.Errorf("Get() tag = %s, want %s", meta.DeviceTag, tt.wantTag)
+			}
+			if got := source.calls.Load(); got != tt.wantCalls {
+				t.Errorf("source calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+
+	if v, _ := mr.Get("device-meta:x"); v != notFound {
+		t.Errorf("redis negative entry = %q", v)
+	}
+	if ttl := mr.TTL("device-meta:a"); ttl != time.Hour {
+		t.Errorf("redis ttl = %v, want 1h", ttl)
+	}
+
+	// a second instance reads through redis without touching the source
+	other := NewMetaCache(source, rdb, MetaCacheConfig{})
+	if meta, err := other.Get(ctx, "a"); err != nil || meta.CompanyUID != "c1" {
+		t.Errorf("Get() from redis = %v, %v", meta, err)
+	}
+	if got := source.calls.Load(); got != 2 {
+		t.Errorf("source calls = %d, want 2", got)
+	}
+}
+
+func TestMetaCache_Singleflight(t *testing.T) {
+	source := newTestSource(t)
+	source.release = make(chan struct{})
+	c := NewMetaCache(source, nil, MetaCacheConfig{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Get(context.Background(), "a"); err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+		}()
+	}
+	for source.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(source.release)
+	wg.Wait()
+
+	if got := source.calls.Load(); got != 1 {
+		t.Errorf("source calls = %d, want 1", got)
+	}
+}
+
+func TestMetaCache_Invalidate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source := newTestSource(t)
+	mr, rdb := newTestRedis(t)
+	c := NewMetaCache(source, rdb, MetaCacheConfig{})
+	other := NewMetaCache(source, rdb, MetaCacheConfig{})
+
+	listening := make(chan error, 1)
+	go func() {
+		listening <- other.Listen(ctx)
+	}()
+	for len(mr.PubSubChannels("")) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := other.Get(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.UpsertDevice(ctx, &lib.Device{DeviceUID: "a", DeviceMeta: &lib.DeviceMeta{DeviceTag: "freezer-4b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Invalidate(ctx, "a"); err != nil {
+		t.Fatalf("Invalidate() error = %v", err)
+	}
+	if mr.Exists("device-meta:a") {
+		t.Errorf("Invalidate() left the redis entry")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for other.local.len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	meta, err := other.Get(ctx, "a")
+	if err != nil || meta.DeviceTag != "freezer-4b" {
+		t.Errorf("Get() after invalidate = %v, %v", meta, err)
+	}
+
+	cancel()
+	if err = <-listening; err != nil {
+		t.Errorf("Listen() error = %v", err)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	l := newLRU(2)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	l.add("a", &lib.DeviceMeta{}, time.Minute)
+	l.add("b", &lib.DeviceMeta{}, time.Minute)
+	l.get("a")
+	l.add("c", &lib.DeviceMeta{}, time.Minute)
+	if _, ok := l.get("b"); ok {
+		t.Errorf("least recently used entry was kept")
+	}
+	if _, ok := l.get("a"); !ok {
+		t.Errorf("recently used entry was evicted")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := l.get("a"); ok {
+		t.Errorf("expired entry was returned")
+	}
+	if l.len() != 1 {
+		t.Errorf("len() = %d, want 1", l.len())
+	}
+}
+
+func TestMetaCache_InvalidateDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	source := newTestSource(t)
+	source.release = make(chan struct{})
+	mr, rdb := newTestRedis(t)
+	c := NewMetaCache(source, rdb, MetaCacheConfig{})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "a")
+		done <- err
+	}()
+	for source.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Invalidate(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	close(source.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if c.local.len() != 0 || mr.Exists("device-meta:a") {
+		t.Errorf("a load started before Invalidate cached its result")
+	}
+}
+
+func TestMetaCache_CallerCancelled(t *testing.T) {
+	source := newTestSource(t)
+	source.release = make(chan struct{})
+	c := NewMetaCache(source, nil, MetaCacheConfig{})
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Get(cancelled, "a")
+		first <- err
+	}()
+	for source.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan error, 1)
+	go func() {
+		_, err := c.Get(context.Background(), "a")
+		second <- err
+	}()
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Get() with cancelled context error = %v", err)
+	}
+	close(source.release)
+	if err := <-second; err != nil {
+		t.Errorf("Get() sharing the cancelled caller's load error = %v", err)
+	}
+	if got := source.calls.Load(); got != 1 {
+		t.Errorf("source calls = %d, want 1", got)
+	}
+}
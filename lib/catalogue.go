@@ -0,0 +1,248 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrUnknownDeviceType  = errors.New("unknown device type")
+	ErrUnknownDeviceGroup = errors.New("unknown device group")
+	// ErrInvalidCategory is returned for a known type and group that aren't allowed together
+	ErrInvalidCategory = errors.New("invalid category")
+)
+
+// TypeInfo describes a DeviceType in a Catalogue.
+// RequiredFields are DeviceMeta json field names that devices of the type must set e.g. "locationUID".
+type TypeInfo struct {
+	Type           DeviceType `json:"type"`
+	Description    string     `json:"description"`
+	Units          []Unit     `json:"units,omitempty"`
+	RequiredFields []string   `json:"requiredFields,omitempty"`
+}
+
+// GroupInfo describes a DeviceGroup and the types it can be combined with in a Category
+type GroupInfo struct {
+	Group          DeviceGroup  `json:"group"`
+	Description    string       `json:"description"`
+	Types          []DeviceType `json:"types"`
+	Units          []Unit       `json:"units,omitempty"`
+	RequiredFields []string     `json:"requiredFields,omitempty"`
+}
+
+// Catalogue registers the device types and groups a deployment knows about so Category values can be checked
+type Catalogue struct {
+	mu     sync.RWMutex
+	types  map[DeviceType]TypeInfo
+	groups map[DeviceGroup]GroupInfo
+}
+
+func NewCatalogue() *Catalogue {
+	return &Catalogue{
+		types:  make(map[DeviceType]TypeInfo),
+		groups: make(map[DeviceGroup]GroupInfo),
+	}
+}
+
+// DefaultCatalogue holds the types and groups defined in this package
+var DefaultCatalogue = newDefaultCatalogue()
+
+func newDefaultCatalogue() *Catalogue {
+	c := NewCatalogue()
+	for _, t := range []TypeInfo{
+		{Type: Power, Description: "power monitoring", Units: []Unit{KilowattHour}},
+		{Type: Lighting, Description: "lighting control"},
+	} {
+		if err := c.RegisterType(t); err != nil {
+			panic(err)
+		}
+	}
+	for _, g := range []GroupInfo{
+		{Group: Meter, Description: "energy meter", Types: []DeviceType{Power}, Units: []Unit{KilowattHour}},
+		{Group: DaliEL, Description: "DALI emergency lighting", Types: []DeviceType{Lighting}, RequiredFields: []string{"locationUID"}},
+	} {
+		if err := c.RegisterGroup(g); err != nil {
+			panic(err)
+		}
+	}
+	return c
+}
+
+// RegisterType adds or replaces a type
+func (c *Catalogue) RegisterType(t TypeInfo) error {
+	if t.Type == "" {
+		return fmt.Errorf("device type needs a name")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.types[t.Type] = t
+	return nil
+}
+
+// RegisterGroup adds or replaces a group, its Types must already be registered
+func (c *Catalogue) RegisterGroup(g GroupInfo) error {
+	if g.Group == "" {
+		return fmt.Errorf("device group needs a name")
+	}
+	if len(g.Types) == 0 {
+		return fmt.Errorf("device group %s needs at least one type", g.Group)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range g.Types {
+		if _, ok := c.types[t]; !ok {
+			return fmt.Errorf("group %s: %w: %s", g.Group, ErrUnknownDeviceType, t)
+		}
+	}
+	c.groups[g.Group] = g
+	return nil
+}
+
+func (c *Catalogue) Type(t DeviceType) (TypeInfo, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	info, ok := c.types[t]
+	if !ok {
+		return TypeInfo{}, fmt.Errorf("%w: %s", ErrUnknownDeviceType, t)
+	}
+	return info, nil
+}
+
+func (c *Catalogue) Group(g DeviceGroup) (GroupInfo, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	info, ok := c.groups[g]
+	if !ok {
+		return GroupInfo{}, fmt.Errorf("%w: %s", ErrUnknownDeviceGroup, g)
+	}
+	return info, nil
+}
+
+// Types lists the registered types by name
+func (c *Catalogue) Types() []TypeInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	types := make([]TypeInfo, 0, len(c.types))
+	for _, t := range c.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Type < types[j].Type
+	})
+	return types
+}
+
+// Groups lists the groups allowed with t by name, an empty t lists every group
+func (c *Catalogue) Groups(t DeviceType) []GroupInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var groups []GroupInfo
+	for _, g := range c.groups {
+		if t == "" || g.allows(t) {
+			groups = append(groups, g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Group < groups[j].Group
+	})
+	return groups
+}
+
+func (g GroupInfo) allows(t DeviceType) bool {
+	for _, allowed := range g.Types {
+		if allowed == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the category's type and group are registered and allowed together, empty fields are not checked
+func (c *Catalogue) Validate(category Category) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if category.DeviceType != "" {
+		if _, ok := c.types[category.DeviceType]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownDeviceType, category.DeviceType)
+		}
+	}
+	if category.DeviceGroup == "" {
+		return nil
+	}
+	g, ok := c.groups[category.DeviceGroup]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownDeviceGroup, category.DeviceGroup)
+	}
+	if category.DeviceType != "" && !g.allows(category.DeviceType) {
+		return fmt.Errorf("%w: %s is not a %s group", ErrInvalidCategory, category.DeviceGroup, category.DeviceType)
+	}
+	return nil
+}
+
+// Units gives the units readings of the category are expected in, the group's units if it has any else the type's
+func (c *Catalogue) Units(category Category) ([]Unit, error) {
+	if err := c.Validate(category); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if g, ok := c.groups[category.DeviceGroup]; ok && len(g.Units) > 0 {
+		return g.Units, nil
+	}
+	return c.types[category.DeviceType].Units, nil
+}
+
+// CheckMeta validates the category and that meta sets every field the category's type and group require
+func (c *Catalogue) CheckMeta(category Category, meta *DeviceMeta) error {
+	if err := c.Validate(category); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	required := append([]string{}, c.types[category.DeviceType].RequiredFields...)
+	required = append(required, c.groups[category.DeviceGroup].RequiredFields...)
+	c.mu.RUnlock()
+	if len(required) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]interface{})
+	if meta != nil {
+		b, err := json.Marshal(meta)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(b, &fields); err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for _, f := range required {
+		if _, ok := fields[f]; !ok {
+			errs = append(errs, fmt.Errorf("%s/%s device needs %s", category.DeviceType, category.DeviceGroup, f))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DecodeCategory reads a Category from json and validates it, use it where categories are accepted as input.
+// Category json itself decodes any type and group so stored data and caller registered types stay readable.
+func (c *Catalogue) DecodeCategory(data []byte) (Category, error) {
+	var category Category
+	if err := json.Unmarshal(data, &category); err != nil {
+		return Category{}, err
+	}
+	if err := c.Validate(category); err != nil {
+		return Category{}, err
+	}
+	return category, nil
+}
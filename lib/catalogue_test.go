@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCatalogue_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		category Category
+		wantErr  error
+	}{
+		{name: "meter", category: Category{DeviceType: Power, DeviceGroup: Meter}},
+		{name: "type only", category: Category{DeviceType: Lighting}},
+		{name: "empty", category: Category{}},
+		{name: "wrong combination", category: Category{DeviceType: Power, DeviceGroup: DaliEL}, wantErr: ErrInvalidCategory},
+		{name: "unknown type", category: Category{DeviceType: "heating"}, wantErr: ErrUnknownDeviceType},
+		{name: "unknown group", category: Category{DeviceType: Power, DeviceGroup: "solar"}, wantErr: ErrUnknownDeviceGroup},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := DefaultCatalogue.Validate(tt.category); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCatalogue_DecodeCategory(t *testing.T) {
+	c, err := DefaultCatalogue.DecodeCategory([]byte(`{"DeviceType":"power","DeviceGroup":"meter"}`))
+	if err != nil || c.DeviceType != Power || c.DeviceGroup != Meter {
+		t.Errorf("DecodeCategory() = %+v, %v", c, err)
+	}
+	if _, err = DefaultCatalogue.DecodeCategory([]byte(`{"DeviceType":"heating"}`)); !errors.Is(err, ErrUnknownDeviceType) {
+		t.Errorf("DecodeCategory() error = %v, want ErrUnknownDeviceType", err)
+	}
+
+	custom := NewCatalogue()
+	if err = custom.RegisterType(TypeInfo{Type: "heating"}); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = custom.DecodeCategory([]byte(`{"DeviceType":"heating"}`)); err != nil || c.DeviceType != "heating" {
+		t.Errorf("DecodeCategory() with a registered type = %+v, %v", c, err)
+	}
+
+	var s DeviceSkeleton
+	if err = json.Unmarshal([]byte(`{"DeviceUID":"a","DeviceCategory":{"DeviceType":"heating"}}`), &s); err != nil {
+		t.Errorf("Unmarshal() of an unregistered type error = %v, json should stay permissive", err)
+	}
+}
+
+func TestCatalogue(t *testing.T) {
+	c := NewCatalogue()
+	if err := c.RegisterGroup(GroupInfo{Group: "solar", Types: []DeviceType{"generation"}}); !errors.Is(err, ErrUnknownDeviceType) {
+		t.Errorf("RegisterGroup() with an unknown type error = %v", err)
+	}
+	_ = c.RegisterType(TypeInfo{Type: "generation", Units: []Unit{KilowattHour}, RequiredFields: []string{"companyUID"}})
+	_ = c.RegisterType(TypeInfo{Type: Power})
+	if err := c.RegisterGroup(GroupInfo{Group: "solar", Types: []DeviceType{"generation"}, RequiredFields: []string{"locationUID"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Types()) != 2 || len(c.Groups("generation")) != 1 || len(c.Groups(Power)) != 0 {
+		t.Errorf("Types() = %v, Groups() = %v", c.Types(), c.Groups(""))
+	}
+	solar := Category{DeviceType: "generation", DeviceGroup: "solar"}
+	if units, err := c.Units(solar); err != nil || len(units) != 1 || units[0] != KilowattHour {
+		t.Errorf("Units() = %v, %v", units, err)
+	}
+	if err := c.CheckMeta(solar, &DeviceMeta{CompanyUID: "c1"}); err == nil {
+		t.Errorf("CheckMeta() should want a locationUID")
+	}
+	if err := c.CheckMeta(solar, &DeviceMeta{CompanyUID: "c1", LocationUID: "roof"}); err != nil {
+		t.Errorf("CheckMeta() error = %v", err)
+	}
+}
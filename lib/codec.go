@@ -0,0 +1,221 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrNoCodec is returned when no registered codec accepts a payload
+	ErrNoCodec = errors.New("no codec for payload")
+	// ErrUnsupportedPayload is returned by a codec that does not handle a payload, the next codec in the chain is tried
+	ErrUnsupportedPayload = errors.New("payload not supported by codec")
+	// ErrMalformedPayload is returned by a codec that handles a payload but finds it corrupt, no fallback is tried
+	ErrMalformedPayload = errors.New("malformed payload")
+)
+
+// DecodeError records which codec failed, errors.Is classifies it against ErrNoCodec, ErrUnsupportedPayload and
+// ErrMalformedPayload
+type DecodeError struct {
+	Codec string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Codec == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("codec %s: %v", e.Codec, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecoderFunc turns a device payload into a typed reading
+type DecoderFunc func(payload []byte) (interface{}, error)
+
+// FirmwareRange selects firmware by name and version range, empty fields match any firmware
+type FirmwareRange struct {
+	Name string `json:"name,omitempty"`
+	// MinVersion is inclusive
+	MinVersion string `json:"minVersion,omitempty"`
+	// MaxVersion is exclusive
+	MaxVersion string `json:"maxVersion,omitempty"`
+}
+
+// Validate checks the range's versions parse
+func (fr FirmwareRange) Validate() error {
+	for _, v := range []string{fr.MinVersion, fr.MaxVersion} {
+		if v == "" {
+			continue
+		}
+		if _, err := ParseVersion(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Matches reports whether firmware falls in the range, a nil firmware only matches an empty range and firmware with
+// an unparseable version only matches a range without versions, Register refuses unparseable ranges
+func (fr FirmwareRange) Matches(firmware *Firmware) bool {
+	if fr == (FirmwareRange{}) {
+		return true
+	}
+	if firmware == nil {
+		return false
+	}
+	if fr.Name != "" && fr.Name != firmware.FirmwareName {
+		return false
+	}
+	if fr.MinVersion == "" && fr.MaxVersion == "" {
+		return true
+	}
+	v, err := firmware.ParsedVersion()
+	if err != nil {
+		return false
+	}
+	if fr.MinVersion != "" {
+		min, err := ParseVersion(fr.MinVersion)
+		if err != nil || v.Less(min) {
+			return false
+		}
+	}
+	if fr.MaxVersion != "" {
+		max, err := ParseVersion(fr.MaxVersion)
+		if err != nil || !v.Less(max) {
+			return false
+		}
+	}
+	return true
+}
+
+// CodecRegistration is a decoder and the devices it applies to.
+// Empty DeviceType or DeviceGroup in the Category match any device.
+type CodecRegistration struct {
+	Name     string        `json:"name"`
+	Category Category      `json:"category"`
+	Firmware FirmwareRange `json:"firmware"`
+	Decode   DecoderFunc   `json:"-"`
+}
+
+func (c CodecRegistration) matches(category Category, firmware *Firmware) bool {
+	if c.Category.DeviceType != "" && c.Category.DeviceType != category.DeviceType {
+		return false
+	}
+	if c.Category.DeviceGroup != "" && c.Category.DeviceGroup != category.DeviceGroup {
+		return false
+	}
+	return c.Firmware.Matches(firmware)
+}
+
+// specificity orders the fallback chain, the most specific codec is tried first
+func (c CodecRegistration) specificity() int {
+	s := 0
+	if c.Category.DeviceType != "" {
+		s += 8
+	}
+	if c.Category.DeviceGroup != "" {
+		s += 8
+	}
+	if c.Firmware.Name != "" {
+		s += 2
+	}
+	if c.Firmware.MinVersion != "" || c.Firmware.MaxVersion != "" {
+		s++
+	}
+	return s
+}
+
+// CodecRegistry finds the decoders for a device's payload by Category and Firmware
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	codecs []CodecRegistration
+}
+
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{}
+}
+
+func (r *CodecRegistry) Register(c CodecRegistration) error {
+	if c.Name == "" {
+		return fmt.Errorf("codec needs a name")
+	}
+	if c.Decode == nil {
+		return fmt.Errorf("codec %s has no decoder", c.Name)
+	}
+	if err := c.Firmware.Validate(); err != nil {
+		return fmt.Errorf("codec %s firmware range: %w", c.Name, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.codecs {
+		if existing.Name == c.Name {
+			return fmt.Errorf("codec %s is already registered", c.Name)
+		}
+	}
+	r.codecs = append(r.codecs, c)
+	return nil
+}
+
+// Codecs lists the registered codecs in registration order
+func (r *CodecRegistry) Codecs() []CodecRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	codecs := make([]CodecRegistration, len(r.codecs))
+	copy(codecs, r.codecs)
+	return codecs
+}
+
+// Lookup gives the fallback chain for a device, most specific first then in registration order
+func (r *CodecRegistry) Lookup(category Category, firmware *Firmware) []CodecRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var chain []CodecRegistration
+	for _, c := range r.codecs {
+		if c.matches(category, firmware) {
+			chain = append(chain, c)
+		}
+	}
+	sort.SliceStable(chain, func(i, j int) bool {
+		return chain[i].specificity() > chain[j].specificity()
+	})
+	return chain
+}
+
+// Decode runs the fallback chain on payload returning the first reading and the name of the codec that produced it.
+// Codecs returning ErrUnsupportedPayload pass to the next in the chain, any other error stops decoding.
+func (r *CodecRegistry) Decode(category Category, firmware *Firmware, payload []byte) (interface{}, string, error) {
+	chain := r.Lookup(category, firmware)
+	for _, c := range chain {
+		reading, err := c.Decode(payload)
+		if err == nil {
+			return reading, c.Name, nil
+		}
+		if errors.Is(err, ErrUnsupportedPayload) {
+			continue
+		}
+		return nil, c.Name, &DecodeError{Codec: c.Name, Err: err}
+	}
+	return nil, "", &DecodeError{Err: fmt.Errorf("%w: %d codecs tried for %s/%s", ErrNoCodec, len(chain), category.DeviceType, category.DeviceGroup)}
+}
+
+// DecodeAs decodes payload and checks the reading is a T
+func DecodeAs[T any](r *CodecRegistry, category Category, firmware *Firmware, payload []byte) (T, error) {
+	var t T
+	reading, name, err := r.Decode(category, firmware, payload)
+	if err != nil {
+		return t, err
+	}
+	t, ok := reading.(T)
+	if !ok {
+		return t, &DecodeError{Codec: name, Err: fmt.Errorf("reading is %T not %T", reading, t)}
+	}
+	return t, nil
+}
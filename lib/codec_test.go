@@ -0,0 +1,165 @@
+package lib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type powerReading struct {
+	Watts uint16
+}
+
+func newTestCodecRegistry(t *testing.T) *CodecRegistry {
+	r := NewCodecRegistry()
+	codecs := []CodecRegistration{
+		{
+			Name: "generic",
+			Decode: func(payload []byte) (interface{}, error) {
+				return string(payload), nil
+			},
+		},
+		{
+			Name:     "power",
+			Category: Category{DeviceType: Power},
+			Decode: func(payload []byte) (interface{}, error) {
+				if len(payload) != 2 {
+					return nil, fmt.Errorf("%w: want 2 bytes got %d", ErrMalformedPayload, len(payload))
+				}
+				return powerReading{Watts: binary.BigEndian.Uint16(payload)}, nil
+			},
+		},
+		{
+			Name:     "power-meter-v2",
+			Category: Category{DeviceType: Power, DeviceGroup: Meter},
+			Firmware: FirmwareRange{Name: "pm", MinVersion: "2.0", MaxVersion: "3.0"},
+			Decode: func(payload []byte) (interface{}, error) {
+				if payload[0] != 0x02 {
+					return nil, ErrUnsupportedPayload
+				}
+				return powerReading{Watts: uint16(payload[1]) * 10}, nil
+			},
+		},
+	}
+	for _, c := range codecs {
+		if err := r.Register(c); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+	return r
+}
+
+func TestCodecRegistry_Decode(t *testing.T) {
+	r := newTestCodecRegistry(t)
+	meter := Category{DeviceType: Power, DeviceGroup: Meter}
+
+	tests := []struct {
+		name      string
+		category  Category
+		firmware  *Firmware
+		payload   []byte
+		want      interface{}
+		wantCodec string
+		wantErr   error
+	}{
+		{
+			name:      "specific firmware",
+			category:  meter,
+			firmware:  &Firmware{FirmwareName: "pm", FirmwareVersion: "2.10"},
+			payload:   []byte{0x02, 0x05},
+			want:      powerReading{Watts: 50},
+			wantCodec: "power-meter-v2",
+		},
+		{
+			name:      "falls back when unsupported",
+			category:  meter,
+			firmware:  &Firmware{FirmwareName: "pm", FirmwareVersion: "2.1"},
+			payload:   []byte{0x01, 0x05},
+			want:      powerReading{Watts: 0x0105},
+			wantCodec: "power",
+		},
+		{
+			name:      "firmware out of range",
+			category:  meter,
+			firmware:  &Firmware{FirmwareName: "pm", FirmwareVersion: "3.0"},
+			payload:   []byte{0x02, 0x05},
+			want:      powerReading{Watts: 0x0205},
+			wantCodec: "power",
+		},
+		{
+			name:      "malformed stops the chain",
+			category:  Category{DeviceType: Power},
+			payload:   []byte{0x01},
+			wantCodec: "power",
+			wantErr:   ErrMalformedPayload,
+		},
+		{
+			name:      "generic",
+			category:  Category{DeviceType: Lighting},
+			payload:   []byte("on"),
+			want:      "on",
+			wantCodec: "generic",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, codec, err := r.Decode(tt.category, tt.firmware, tt.payload)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if codec != tt.wantCodec || got != tt.want {
+				t.Errorf("Decode() = %v by %s, want %v by %s", got, codec, tt.want, tt.wantCodec)
+			}
+		})
+	}
+}
+
+func TestCodecRegistry_NoCodec(t *testing.T) {
+	r := NewCodecRegistry()
+	_, _, err := r.Decode(Category{DeviceType: Power}, nil, []byte{})
+	if !errors.Is(err, ErrNoCodec) {
+		t.Errorf("Decode() error = %v, want ErrNoCodec", err)
+	}
+	if err = r.Register(CodecRegistration{Name: "nil"}); err == nil {
+		t.Errorf("Register() should refuse a codec without a decoder")
+	}
+}
+
+func TestDecodeAs(t *testing.T) {
+	r := newTestCodecRegistry(t)
+	got, err := DecodeAs[powerReading](r, Category{DeviceType: Power}, nil, []byte{0x00, 0x10})
+	if err != nil || got.Watts != 16 {
+		t.Errorf("DecodeAs() = %v, %v", got, err)
+	}
+	if _, err = DecodeAs[powerReading](r, Category{DeviceType: Lighting}, nil, []byte("on")); err == nil {
+		t.Errorf("DecodeAs() should fail on the wrong reading type")
+	}
+}
+
+func TestCodecRegistry_Lookup(t *testing.T) {
+	r := newTestCodecRegistry(t)
+	chain := r.Lookup(Category{DeviceType: Power, DeviceGroup: Meter}, &Firmware{FirmwareName: "pm", FirmwareVersion: "2.0"})
+	var names []string
+	for _, c := range chain {
+		names = append(names, c.Name)
+	}
+	if fmt.Sprint(names) != "[power-meter-v2 power generic]" {
+		t.Errorf("Lookup() = %v", names)
+	}
+	if len(r.Codecs()) != 3 {
+		t.Errorf("Codecs() = %d codecs, want 3", len(r.Codecs()))
+	}
+}
+
+func TestCodecRegistry_RegisterInvalidRange(t *testing.T) {
+	r := NewCodecRegistry()
+	err := r.Register(CodecRegistration{
+		Name:     "bad",
+		Firmware: FirmwareRange{MinVersion: "two"},
+		Decode:   func(payload []byte) (interface{}, error) { return nil, nil },
+	})
+	if !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("Register() error = %v, want ErrInvalidVersion", err)
+	}
+}
@@ -13,6 +13,8 @@ type Device struct {
 //
 // # The metadata can be added to passed messages so a cache is not needed to query the structure needed for microservice
 //
+// Services that do look it up per message should go through cache.MetaCache.
+//
 // DeviceName is a human-readable identifier for the device which may or may not be unique
 // DeviceTag identifies the function of the device in its environment and should remain constant if the device is
 // switched out due to failure or replacement but the function of the device remains constant
@@ -21,7 +23,9 @@ type Device struct {
 //
 //	the cooler in room 4B is still the cooler in 4B even if it is upgraded from PhilipsKA4500 to PhilipsKA4501
 //
-// CompanyUID and LocationUID remain as basic organizational elements
+// CompanyUID and LocationUID remain as basic organizational elements,
+// Status is the device's LifecycleState, an empty Status is treated as Active so devices stored before lifecycles
+// keep flowing
 type DeviceMeta struct {
 	DeviceName string     `datastore:",omitempty" firestore:"name,omitempty" json:"deviceName,omitempty"`
 	DeviceTag  string     `datastore:",omitempty" firestore:"tag,omitempty" json:"deviceTag,omitempty"`
@@ -33,6 +37,10 @@ type DeviceMeta struct {
 	Firmware *Firmware `datastore:",omitempty" firestore:"firmware,omitempty" json:"firmware,omitempty"`
 	//Datastore can't handle map[string]interface so a datastore implementation will need to serialize the specific processors it needs
 	Processors *Processor `datastore:"-" firestore:"processors,omitempty" json:"processors,omitempty"`
+
+	Status LifecycleState `datastore:",omitempty" firestore:"status,omitempty" json:"status,omitempty"`
+	// StatusHistory holds the last MaxStatusHistory transitions
+	StatusHistory []LifecycleTransition `datastore:",omitempty" firestore:"statusHistory,omitempty" json:"statusHistory,omitempty"`
 }
 
 // Processor allows implementations to save a number of identifiers for different processing options
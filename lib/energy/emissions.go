@@ -0,0 +1,271 @@
+package energy
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"github.com/safecility/go/lib/gbigquery"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoIntensity = errors.New("no grid intensity")
+
+// FactorSource names where an intensity factor came from e.g. a national inventory and its edition
+type FactorSource struct {
+	Source  string `json:"source" bigquery:"source"`
+	Version string `json:"version" bigquery:"version"`
+}
+
+// Intensity is a grid carbon intensity in kgCO2e per kWh, valid until Until
+type Intensity struct {
+	KgPerKWh float64
+	Until    time.Time
+	FactorSource
+}
+
+// IntensitySource gives the intensity for a region at t, returning ErrNoIntensity when it has none
+type IntensitySource interface {
+	Intensity(region string, t time.Time) (Intensity, error)
+}
+
+// YearlyIntensity is a static factor for a region over a calendar year (UTC)
+type YearlyIntensity struct {
+	Region   string  `json:"region"`
+	Year     int     `json:"year"`
+	KgPerKWh float64 `json:"kgPerKWh"`
+}
+
+// StaticIntensity holds yearly factors such as those published annually for company reporting
+type StaticIntensity struct {
+	source  FactorSource
+	factors map[string]map[int]float64
+}
+
+func NewStaticIntensity(source FactorSource, factors ...YearlyIntensity) *StaticIntensity {
+	s := &StaticIntensity{source: source, factors: make(map[string]map[int]float64)}
+	for _, f := range factors {
+		if s.factors[f.Region] == nil {
+			s.factors[f.Region] = make(map[int]float64)
+		}
+		s.factors[f.Region][f.Year] = f.KgPerKWh
+	}
+	return s
+}
+
+func (s *StaticIntensity) Intensity(region string, t time.Time) (Intensity, error) {
+	year := t.UTC().Year()
+	f, ok := s.factors[region][year]
+	if !ok {
+		return Intensity{}, fmt.Errorf("%w: %s has no %d factor in %s %s", ErrNoIntensity, region, year, s.source.Source, s.source.Version)
+	}
+	return Intensity{
+		KgPerKWh:     f,
+		Until:        time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC),
+		FactorSource: s.source,
+	}, nil
+}
+
+type intensityPeriod struct {
+	from     time.Time
+	kgPerKWh float64
+}
+
+// SeriesIntensity is a time series of factors each lasting Period, typically the half hourly grid intensity
+type SeriesIntensity struct {
+	source  FactorSource
+	period  time.Duration
+	regions map[string][]intensityPeriod
+}
+
+// LoadIntensityCSV reads a series with the header region,from,intensity where from is RFC 3339 and intensity is in
+// gCO2e/kWh as grid operators publish it. Each row lasts period, 0 is half an hour.
+func LoadIntensityCSV(r io.Reader, source FactorSource, period time.Duration) (*SeriesIntensity, error) {
+	if period <= 0 {
+		period = 30 * time.Minute
+	}
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("intensity csv header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, c := range []string{"region", "from", "intensity"} {
+		if _, ok := columns[c]; !ok {
+			return nil, fmt.Errorf("intensity csv has no %s column", c)
+		}
+	}
+
+	s := &SeriesIntensity{source: source, period: period, regions: make(map[string][]intensityPeriod)}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("intensity csv: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		from, err := time.Parse(time.RFC3339, record[columns["from"]])
+		if err != nil {
+			return nil, fmt.Errorf("intensity csv line %d: %w", line, err)
+		}
+		g, err := strconv.ParseFloat(record[columns["intensity"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("intensity csv line %d: %w", line, err)
+		}
+		region := record[columns["region"]]
+		s.regions[region] = append(s.regions[region], intensityPeriod{from: from.UTC(), kgPerKWh: g / 1000})
+	}
+	for _, periods := range s.regions {
+		sort.Slice(periods, func(i, j int) bool {
+			return periods[i].from.Before(periods[j].from)
+		})
+	}
+	return s, nil
+}
+
+func (s *SeriesIntensity) Intensity(region string, t time.Time) (Intensity, error) {
+	periods := s.regions[region]
+	i := sort.Search(len(periods), func(i int) bool {
+		return periods[i].from.After(t)
+	}) - 1
+	if i < 0 || !t.Before(periods[i].from.Add(s.period)) {
+		return Intensity{}, fmt.Errorf("%w: %s has no factor at %s in %s %s", ErrNoIntensity, region, t, s.source.Source, s.source.Version)
+	}
+	return Intensity{KgPerKWh: periods[i].kgPerKWh, Until: periods[i].from.Add(s.period), FactorSource: s.source}, nil
+}
+
+// IntensityFallback tries each source in turn, e.g. a half hourly series then the yearly factor for gaps in it
+type IntensityFallback []IntensitySource
+
+func (f IntensityFallback) Intensity(region string, t time.Time) (Intensity, error) {
+	err := fmt.Errorf("%w: %s has no sources", ErrNoIntensity, region)
+	for _, s := range f {
+		var i Intensity
+		if i, err = s.Intensity(region, t); err == nil || !errors.Is(err, ErrNoIntensity) {
+			return i, err
+		}
+	}
+	return Intensity{}, err
+}
+
+var (
+	_ IntensitySource = (*StaticIntensity)(nil)
+	_ IntensitySource = (*SeriesIntensity)(nil)
+	_ IntensitySource = IntensityFallback(nil)
+)
+
+// Estimate is the emissions for some consumption with the factors used to work it out
+type Estimate struct {
+	KgCO2e  float64        `json:"kgCO2e" bigquery:"kgCO2e"`
+	Factors []FactorSource `json:"factors" bigquery:"factors"`
+}
+
+func (e *Estimate) add(kg float64, source FactorSource) {
+	e.KgCO2e += kg
+	for _, f := range e.Factors {
+		if f == source {
+			return
+		}
+	}
+	e.Factors = append(e.Factors, source)
+}
+
+// Emission of a bucket, MarketBased is nil unless the calculator has a market source
+type Emission struct {
+	Bucket        gbigquery.Bucket `json:"bucket"`
+	Consumption   lib.Quantity     `json:"consumption"`
+	LocationBased Estimate         `json:"locationBased"`
+	MarketBased   *Estimate        `json:"marketBased,omitempty"`
+}
+
+type EmissionsResult struct {
+	Region        string     `json:"region"`
+	Emissions     []Emission `json:"emissions"`
+	LocationBased Estimate   `json:"locationBased"`
+	MarketBased   *Estimate  `json:"marketBased,omitempty"`
+}
+
+// EmissionsConfig picks the grid region and the factors for each reporting method, Location is the grid average
+// and Market the supplier or contractual factor (a StaticIntensity of 0 for certified renewable supply)
+type EmissionsConfig struct {
+	Region   string
+	Location IntensitySource
+	Market   IntensitySource
+}
+
+// EmissionsCalculator converts bucketed consumption to kgCO2e
+type EmissionsCalculator struct {
+	config EmissionsConfig
+}
+
+func NewEmissionsCalculator(config EmissionsConfig) (*EmissionsCalculator, error) {
+	if config.Region == "" || config.Location == nil {
+		return nil, fmt.Errorf("emissions need a region and location based intensity")
+	}
+	return &EmissionsCalculator{config: config}, nil
+}
+
+// Calculate gives the emissions of each bucket, a bucket covering several factor periods is shared between them
+// in proportion to time
+func (c *EmissionsCalculator) Calculate(consumption []Consumption) (*EmissionsResult, error) {
+	result := &EmissionsResult{Region: c.config.Region, Emissions: make([]Emission, 0, len(consumption))}
+	if c.config.Market != nil {
+		result.MarketBased = &Estimate{}
+	}
+	for _, b := range consumption {
+		q, err := b.Value.Convert(lib.KilowattHour)
+		if err != nil {
+			return nil, fmt.Errorf("bucket %s: %w", b.Bucket.StartTime, err)
+		}
+		e := Emission{Bucket: b.Bucket, Consumption: q}
+		if err = c.estimate(&e.LocationBased, c.config.Location, b.Bucket, q.Value); err != nil {
+			return nil, fmt.Errorf("location based: %w", err)
+		}
+		for _, f := range e.LocationBased.Factors {
+			result.LocationBased.add(0, f)
+		}
+		result.LocationBased.KgCO2e += e.LocationBased.KgCO2e
+		if c.config.Market != nil {
+			e.MarketBased = &Estimate{}
+			if err = c.estimate(e.MarketBased, c.config.Market, b.Bucket, q.Value); err != nil {
+				return nil, fmt.Errorf("market based: %w", err)
+			}
+			for _, f := range e.MarketBased.Factors {
+				result.MarketBased.add(0, f)
+			}
+			result.MarketBased.KgCO2e += e.MarketBased.KgCO2e
+		}
+		result.Emissions = append(result.Emissions, e)
+	}
+	return result, nil
+}
+
+func (c *EmissionsCalculator) estimate(e *Estimate, source IntensitySource, b gbigquery.Bucket, kwh float64) error {
+	if b.Duration <= 0 {
+		return fmt.Errorf("bucket %s has no duration", b.StartTime)
+	}
+	end := b.End()
+	for t := b.StartTime; t.Before(end); {
+		i, err := source.Intensity(c.config.Region, t)
+		if err != nil {
+			return err
+		}
+		until := end
+		if i.Until.After(t) && i.Until.Before(end) {
+			until = i.Until
+		}
+		e.add(kwh*float64(until.Sub(t))/float64(b.Duration)*i.KgPerKWh, i.FactorSource)
+		t = until
+	}
+	return nil
+}
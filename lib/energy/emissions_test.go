@@ -0,0 +1,139 @@
+package energy
+
+import (
+	"errors"
+	"github.com/safecility/go/lib/gbigquery"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const halfHourly = `region,from,intensity
+IE,2024-03-01T00:00:00Z,300
+IE,2024-03-01T00:30:00Z,200
+IE,2024-03-01T01:00:00Z,100
+GB,2024-03-01T00:00:00Z,150
+`
+
+func TestEmissionsCalculator_Calculate(t *testing.T) {
+	grid := FactorSource{Source: "grid", Version: "2024-03"}
+	inventory := FactorSource{Source: "inventory", Version: "2024"}
+	supplier := FactorSource{Source: "supplier", Version: "green-2024"}
+	halfHour, err := LoadIntensityCSV(strings.NewReader(halfHourly), grid, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yearly := NewStaticIntensity(inventory, YearlyIntensity{Region: "IE", Year: 2024, KgPerKWh: 0.25})
+	hourly := gbigquery.BucketType{Interval: gbigquery.HOUR, Multiplier: 1}
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	consumption := series(hourly, start, 10, 10, 10)
+
+	tests := []struct {
+		name        string
+		config      EmissionsConfig
+		want        []float64
+		wantFactors [][]FactorSource
+		wantMarket  float64
+	}{
+		{
+			name:        "yearly",
+			config:      EmissionsConfig{Region: "IE", Location: yearly},
+			want:        []float64{2.5, 2.5, 2.5},
+			wantFactors: [][]FactorSource{{inventory}, {inventory}, {inventory}},
+		},
+		{
+			name:        "half hourly with yearly fallback",
+			config:      EmissionsConfig{Region: "IE", Location: IntensityFallback{halfHour, yearly}},
+			want:        []float64{2.5, 1.75, 2.5},
+			wantFactors: [][]FactorSource{{grid}, {grid, inventory}, {inventory}},
+		},
+		{
+			name: "market based",
+			config: EmissionsConfig{Region: "IE", Location: yearly,
+				Market: NewStaticIntensity(supplier, YearlyIntensity{Region: "IE", Year: 2024})},
+			want:        []float64{2.5, 2.5, 2.5},
+			wantFactors: [][]FactorSource{{inventory}, {inventory}, {inventory}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewEmissionsCalculator(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Calculate(consumption)
+			if err != nil {
+				t.Fatalf("Calculate() error = %v", err)
+			}
+			var total float64
+			for i, e := range got.Emissions {
+				if math.Abs(e.LocationBased.KgCO2e-tt.want[i]) > 1e-9 {
+					t.Errorf("Emissions[%d] = %v kg, want %v", i, e.LocationBased.KgCO2e, tt.want[i])
+				}
+				if !sameFactors(e.LocationBased.Factors, tt.wantFactors[i]) {
+					t.Errorf("Emissions[%d] factors = %v, want %v", i, e.LocationBased.Factors, tt.wantFactors[i])
+				}
+				if (e.MarketBased != nil) != (tt.config.Market != nil) {
+					t.Errorf("Emissions[%d] market based = %v", i, e.MarketBased)
+				}
+				total += tt.want[i]
+			}
+			if math.Abs(got.LocationBased.KgCO2e-total) > 1e-9 {
+				t.Errorf("LocationBased = %v, want %v", got.LocationBased.KgCO2e, total)
+			}
+			if tt.config.Market != nil && (got.MarketBased.KgCO2e != tt.wantMarket || !sameFactors(got.MarketBased.Factors, []FactorSource{supplier})) {
+				t.Errorf("MarketBased = %v", got.MarketBased)
+			}
+		})
+	}
+}
+
+func TestEmissionsCalculator_NoIntensity(t *testing.T) {
+	c, err := NewEmissionsCalculator(EmissionsConfig{Region: "FR", Location: NewStaticIntensity(FactorSource{Source: "inventory"})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumption := series(gbigquery.BucketType{Interval: gbigquery.HOUR}, time.Now(), 1)
+	if _, err = c.Calculate(consumption); !errors.Is(err, ErrNoIntensity) {
+		t.Errorf("Calculate() error = %v, want ErrNoIntensity", err)
+	}
+	if _, err = NewEmissionsCalculator(EmissionsConfig{Region: "FR"}); err == nil {
+		t.Errorf("NewEmissionsCalculator() should need a location based source")
+	}
+}
+
+func TestLoadIntensityCSV(t *testing.T) {
+	for _, csv := range []string{
+		"region,intensity\nIE,100\n",
+		"region,from,intensity\nIE,yesterday,100\n",
+		"region,from,intensity\nIE,2024-03-01T00:00:00Z,high\n",
+	} {
+		if _, err := LoadIntensityCSV(strings.NewReader(csv), FactorSource{}, 0); err == nil {
+			t.Errorf("LoadIntensityCSV(%q) should fail", csv)
+		}
+	}
+	s, err := LoadIntensityCSV(strings.NewReader(halfHourly), FactorSource{Source: "grid"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := s.Intensity("GB", time.Date(2024, 3, 1, 0, 29, 0, 0, time.UTC))
+	if err != nil || i.KgPerKWh != 0.15 || i.Source != "grid" {
+		t.Errorf("Intensity() = %v, %v", i, err)
+	}
+	if _, err = s.Intensity("GB", time.Date(2024, 3, 1, 0, 30, 0, 0, time.UTC)); !errors.Is(err, ErrNoIntensity) {
+		t.Errorf("Intensity() past the series error = %v", err)
+	}
+}
+
+func sameFactors(a, b []FactorSource) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,263 @@
+package energy
+
+import (
+	"fmt"
+	"github.com/safecility/go/lib"
+	"github.com/safecility/go/lib/gbigquery"
+	"math"
+	"sort"
+	"time"
+)
+
+// Reading is a cumulative register value from a lib.Meter device
+type Reading struct {
+	DeviceUID string       `bigquery:"uid" json:"uid"`
+	Time      time.Time    `bigquery:"time" json:"time"`
+	Register  lib.Quantity `bigquery:"register" json:"register"`
+}
+
+type AnomalyKind string
+
+const (
+	// AnomalyNegative is a register going backwards that isn't a rollover or reset, the delta is dropped
+	AnomalyNegative AnomalyKind = "negative"
+	// AnomalyReset is a register restarting near zero e.g. after meter replacement, the new value is the delta
+	AnomalyReset AnomalyKind = "reset"
+	// AnomalyRollover is a register wrapping past its maximum, the delta is counted through the wrap
+	AnomalyRollover AnomalyKind = "rollover"
+	// AnomalySpike is a delta above the plausible rate, it is left out of the consumption
+	AnomalySpike AnomalyKind = "spike"
+	// AnomalyGap is readings further apart than MaxGap, the delta is handled by the GapPolicy
+	AnomalyGap AnomalyKind = "gap"
+	// AnomalyDuplicate is a second reading at the same time with a different value, it is ignored
+	AnomalyDuplicate AnomalyKind = "duplicate"
+)
+
+// Anomaly flags something unusual between the readings at From and To
+type Anomaly struct {
+	Kind  AnomalyKind  `json:"kind"`
+	From  time.Time    `json:"from"`
+	To    time.Time    `json:"to"`
+	Delta lib.Quantity `json:"delta"`
+}
+
+// GapPolicy decides where consumption between readings further apart than MaxGap goes
+type GapPolicy int
+
+const (
+	// GapLinear spreads the delta over the buckets between the readings in proportion to time
+	GapLinear GapPolicy = iota
+	// GapEndBucket puts the delta in the bucket of the later reading
+	GapEndBucket
+	// GapDrop leaves the delta out of the consumption
+	GapDrop
+)
+
+type MeterConfig struct {
+	BucketType gbigquery.BucketType
+	// Unit of the consumption, the default is lib.KilowattHour
+	Unit lib.Unit
+	// Rollover is the register value the meter wraps at, nil for meters that don't wrap.
+	// A drop from the upper half of the register is taken as a rollover.
+	Rollover *lib.Quantity
+	// ResetRatio treats a drop to at most this fraction of the previous register as a reset, the default is 0.1
+	ResetRatio float64
+	// MaxRate is the most Unit plausibly consumed per hour, 0 doesn't check
+	MaxRate float64
+	Gaps    GapPolicy
+	// MaxGap is how far apart readings can be before they are a gap, the default is the bucket duration
+	MaxGap time.Duration
+}
+
+// Consumption is the amount used in a bucket, Estimated is set when some of it was spread across a gap
+type Consumption struct {
+	Bucket    gbigquery.Bucket `json:"bucket"`
+	Value     lib.Quantity     `json:"value"`
+	Estimated bool             `json:"estimated"`
+}
+
+type DeltaResult struct {
+	Consumption []Consumption `json:"consumption"`
+	Anomalies   []Anomaly     `json:"anomalies,omitempty"`
+	Total       lib.Quantity  `json:"total"`
+}
+
+// DeltaCalculator turns cumulative register readings into consumption per bucket
+type DeltaCalculator struct {
+	config   MeterConfig
+	rollover float64
+}
+
+func NewDeltaCalculator(config MeterConfig) (*DeltaCalculator, error) {
+	if config.Unit == "" {
+		config.Unit = lib.KilowattHour
+	}
+	if _, err := config.Unit.Dimension(); err != nil {
+		return nil, err
+	}
+	if config.ResetRatio <= 0 {
+		config.ResetRatio = 0.1
+	}
+	if config.MaxGap <= 0 {
+		config.MaxGap = config.BucketType.Duration()
+	}
+	c := &DeltaCalculator{config: config}
+	if config.Rollover != nil {
+		r, err := config.Rollover.Convert(config.Unit)
+		if err != nil {
+			return nil, fmt.Errorf("rollover: %w", err)
+		}
+		c.rollover = r.Value
+	}
+	return c, nil
+}
+
+type point struct {
+	time  time.Time
+	value float64
+}
+
+// Calculate gives the consumption in every bucket from the first reading to the last.
+// Readings may be out of order and in any unit of the same dimension as the configured Unit.
+func (c *DeltaCalculator) Calculate(readings []Reading) (*DeltaResult, error) {
+	result := &DeltaResult{Total: lib.Quantity{Unit: c.config.Unit}}
+	points := make([]point, 0, len(readings))
+	for _, r := range readings {
+		q, err := r.Register.Convert(c.config.Unit)
+		if err != nil {
+			return nil, fmt.Errorf("reading at %s: %w", r.Time, err)
+		}
+		points = append(points, point{time: r.Time.UTC(), value: q.Value})
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].time.Before(points[j].time)
+	})
+	points = c.dedupe(points, result)
+	if len(points) < 2 {
+		return result, nil
+	}
+
+	buckets := c.config.BucketType.Buckets(gbigquery.QueryInterval{
+		Start: points[0].time,
+		End:   points[len(points)-1].time.Add(time.Nanosecond),
+	})
+	result.Consumption = make([]Consumption, len(buckets))
+	for i, b := range buckets {
+		result.Consumption[i] = Consumption{Bucket: b, Value: lib.Quantity{Unit: c.config.Unit}}
+	}
+
+	// from is the last accepted reading so a dropped reading doesn't become the baseline for the next delta or
+	// shorten the gap to it
+	from := points[0]
+	for i := 1; i < len(points); i++ {
+		to := points[i]
+		delta, ok := c.delta(from, to, result)
+		if !ok {
+			continue
+		}
+		gap := to.time.Sub(from.time) > c.config.MaxGap
+		if delta == 0 || !gap {
+			c.spread(result, from.time, to.time, delta, false)
+			from = to
+			continue
+		}
+		c.flag(result, AnomalyGap, from, to, delta)
+		switch c.config.Gaps {
+		case GapLinear:
+			c.spread(result, from.time, to.time, delta, true)
+		case GapEndBucket:
+			// the later reading closes the interval so a reading on a boundary belongs to the bucket before it
+			end := to.time.Add(-time.Nanosecond)
+			c.spread(result, end, to.time, delta, true)
+		}
+		from = to
+	}
+	for _, b := range result.Consumption {
+		result.Total.Value += b.Value.Value
+	}
+	return result, nil
+}
+
+// dedupe drops readings at the same time as an earlier one, flagging those with different values
+func (c *DeltaCalculator) dedupe(points []point, result *DeltaResult) []point {
+	var kept []point
+	for _, p := range points {
+		if n := len(kept); n > 0 && kept[n-1].time.Equal(p.time) {
+			if kept[n-1].value != p.value {
+				c.flag(result, AnomalyDuplicate, kept[n-1], p, p.value-kept[n-1].value)
+			}
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// delta works out the consumption between readings, reporting false if it should be left out
+func (c *DeltaCalculator) delta(from, to point, result *DeltaResult) (float64, bool) {
+	delta := to.value - from.value
+	if delta < 0 {
+		switch {
+		case c.rollover > 0 && from.value > c.rollover/2:
+			delta = c.rollover - from.value + to.value
+			c.flag(result, AnomalyRollover, from, to, delta)
+		case to.value <= from.value*c.config.ResetRatio:
+			delta = to.value
+			c.flag(result, AnomalyReset, from, to, delta)
+		default:
+			c.flag(result, AnomalyNegative, from, to, delta)
+			return 0, false
+		}
+	}
+	if c.config.MaxRate > 0 {
+		hours := to.time.Sub(from.time).Hours()
+		if delta/hours > c.config.MaxRate {
+			c.flag(result, AnomalySpike, from, to, delta)
+			return 0, false
+		}
+	}
+	return delta, true
+}
+
+// spread shares delta between the buckets overlapping from to in proportion to the overlap
+func (c *DeltaCalculator) spread(result *DeltaResult, from, to time.Time, delta float64, estimated bool) {
+	span := to.Sub(from)
+	for i := range result.Consumption {
+		b := &result.Consumption[i]
+		start := maxTime(from, b.Bucket.StartTime)
+		end := minTime(to, b.Bucket.End())
+		if !end.After(start) {
+			continue
+		}
+		b.Value.Value += delta * float64(end.Sub(start)) / float64(span)
+		b.Estimated = b.Estimated || estimated
+	}
+}
+
+func (c *DeltaCalculator) flag(result *DeltaResult, kind AnomalyKind, from, to point, delta float64) {
+	result.Anomalies = append(result.Anomalies, Anomaly{
+		Kind:  kind,
+		From:  from.time,
+		To:    to.time,
+		Delta: lib.Quantity{Value: roundDelta(delta), Unit: c.config.Unit},
+	})
+}
+
+// roundDelta trims float noise from register subtraction
+func roundDelta(v float64) float64 {
+	return math.Round(v*1e9) / 1e9
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,206 @@
+package energy
+
+import (
+	"github.com/safecility/go/lib"
+	"github.com/safecility/go/lib/gbigquery"
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func reading(minutes int, kwh float64) Reading {
+	return Reading{DeviceUID: "m1", Time: start.Add(time.Duration(minutes) * time.Minute), Register: lib.Quantity{Value: kwh, Unit: lib.KilowattHour}}
+}
+
+func bucketValues(r *DeltaResult) []float64 {
+	var values []float64
+	for _, c := range r.Consumption {
+		values = append(values, math.Round(c.Value.Value*1000)/1000)
+	}
+	return values
+}
+
+func TestDeltaCalculator_Calculate(t *testing.T) {
+	hourly := gbigquery.BucketType{Interval: gbigquery.HOUR, Multiplier: 1}
+	rollover := lib.Quantity{Value: 1000, Unit: lib.KilowattHour}
+
+	tests := []struct {
+		name          string
+		config        MeterConfig
+		readings      []Reading
+		want          []float64
+		wantTotal     float64
+		wantAnomalies []AnomalyKind
+		wantEstimated bool
+	}{
+		{
+			name:      "on the hour",
+			config:    MeterConfig{BucketType: hourly},
+			readings:  []Reading{reading(0, 10), reading(60, 12), reading(120, 15)},
+			want:      []float64{2, 3, 0},
+			wantTotal: 5,
+		},
+		{
+			name:      "out of order with mixed units",
+			config:    MeterConfig{BucketType: hourly},
+			readings:  []Reading{reading(60, 12), {Time: start, Register: lib.Quantity{Value: 10000, Unit: lib.WattHour}}},
+			want:      []float64{2, 0},
+			wantTotal: 2,
+		},
+		{
+			name:      "split across buckets",
+			config:    MeterConfig{BucketType: hourly},
+			readings:  []Reading{reading(30, 10), reading(90, 14)},
+			want:      []float64{2, 2},
+			wantTotal: 4,
+		},
+		{
+			name:          "rollover",
+			config:        MeterConfig{BucketType: hourly, Rollover: &rollover},
+			readings:      []Reading{reading(0, 998), reading(60, 3)},
+			want:          []float64{5, 0},
+			wantTotal:     5,
+			wantAnomalies: []AnomalyKind{AnomalyRollover},
+		},
+		{
+			name:          "reset after replacement",
+			config:        MeterConfig{BucketType: hourly},
+			readings:      []Reading{reading(0, 500), reading(60, 2)},
+			want:          []float64{2, 0},
+			wantTotal:     2,
+			wantAnomalies: []AnomalyKind{AnomalyReset},
+		},
+		{
+			name:          "negative",
+			config:        MeterConfig{BucketType: hourly},
+			readings:      []Reading{reading(0, 500), reading(60, 499), reading(120, 501)},
+			want:          []float64{0.5, 0.5, 0},
+			wantTotal:     1,
+			wantAnomalies: []AnomalyKind{AnomalyNegative, AnomalyGap},
+			wantEstimated: true,
+		},
+		{
+			name:          "dip",
+			config:        MeterConfig{BucketType: hourly},
+			readings:      []Reading{reading(0, 100), reading(60, 95), reading(120, 101)},
+			want:          []float64{0.5, 0.5, 0},
+			wantTotal:     1,
+			wantAnomalies: []AnomalyKind{AnomalyNegative, AnomalyGap},
+			wantEstimated: true,
+		},
+		{
+			name:          "spike",
+			config:        MeterConfig{BucketType: hourly, MaxRate: 50},
+			readings:      []Reading{reading(0, 100), reading(60, 10000), reading(120, 101)},
+			want:          []float64{0.5, 0.5, 0},
+			wantTotal:     1,
+			wantAnomalies: []AnomalyKind{AnomalySpike, AnomalyGap},
+			wantEstimated: true,
+		},
+		{
+			name:          "gap after a dropped reading",
+			config:        MeterConfig{BucketType: hourly, Gaps: GapDrop},
+			readings:      []Reading{reading(0, 100), reading(290, 90), reading(300, 110)},
+			want:          []float64{0, 0, 0, 0, 0, 0},
+			wantAnomalies: []AnomalyKind{AnomalyNegative, AnomalyGap},
+		},
+		{
+			name:          "duplicate",
+			config:        MeterConfig{BucketType: hourly},
+			readings:      []Reading{reading(0, 10), reading(0, 11), reading(0, 10), reading(60, 12)},
+			want:          []float64{2, 0},
+			wantTotal:     2,
+			wantAnomalies: []AnomalyKind{AnomalyDuplicate},
+		},
+		{
+			name:          "gap linear",
+			config:        MeterConfig{BucketType: hourly, Gaps: GapLinear},
+			readings:      []Reading{reading(0, 10), reading(180, 16)},
+			want:          []float64{2, 2, 2, 0},
+			wantTotal:     6,
+			wantAnomalies: []AnomalyKind{AnomalyGap},
+			wantEstimated: true,
+		},
+		{
+			name:          "gap end bucket",
+			config:        MeterConfig{BucketType: hourly, Gaps: GapEndBucket},
+			readings:      []Reading{reading(0, 10), reading(180, 16)},
+			want:          []float64{0, 0, 6, 0},
+			wantTotal:     6,
+			wantAnomalies: []AnomalyKind{AnomalyGap},
+			wantEstimated: true,
+		},
+		{
+			name:          "gap dropped",
+			config:        MeterConfig{BucketType: hourly, Gaps: GapDrop},
+			readings:      []Reading{reading(0, 10), reading(180, 16), reading(240, 17)},
+			want:          []float64{0, 0, 0, 1, 0},
+			wantTotal:     1,
+			wantAnomalies: []AnomalyKind{AnomalyGap},
+		},
+		{
+			name:      "daily",
+			config:    MeterConfig{BucketType: gbigquery.BucketType{Interval: gbigquery.DAY, Multiplier: 1}, Unit: lib.WattHour},
+			readings:  []Reading{reading(12*60, 1), reading(36*60, 2.5)},
+			want:      []float64{750, 750},
+			wantTotal: 1500,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewDeltaCalculator(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Calculate(tt.readings)
+			if err != nil {
+				t.Fatalf("Calculate() error = %v", err)
+			}
+			values := bucketValues(got)
+			if len(values) != len(tt.want) {
+				t.Fatalf("Calculate() = %v, want %v", values, tt.want)
+			}
+			estimated := false
+			for i := range values {
+				if values[i] != tt.want[i] {
+					t.Errorf("Calculate() = %v, want %v", values, tt.want)
+					break
+				}
+				estimated = estimated || got.Consumption[i].Estimated
+			}
+			if math.Abs(got.Total.Value-tt.wantTotal) > 1e-9 {
+				t.Errorf("Total = %v, want %v", got.Total, tt.wantTotal)
+			}
+			if estimated != tt.wantEstimated {
+				t.Errorf("Estimated = %v, want %v", estimated, tt.wantEstimated)
+			}
+			var kinds []AnomalyKind
+			for _, a := range got.Anomalies {
+				kinds = append(kinds, a.Kind)
+			}
+			if len(kinds) != len(tt.wantAnomalies) {
+				t.Fatalf("Anomalies = %v, want %v", kinds, tt.wantAnomalies)
+			}
+			for i := range kinds {
+				if kinds[i] != tt.wantAnomalies[i] {
+					t.Errorf("Anomalies = %v, want %v", kinds, tt.wantAnomalies)
+				}
+			}
+		})
+	}
+}
+
+func TestDeltaCalculator_Units(t *testing.T) {
+	c, err := NewDeltaCalculator(MeterConfig{BucketType: gbigquery.BucketType{Interval: gbigquery.HOUR}, Unit: lib.CubicMetre})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Calculate([]Reading{reading(0, 1), reading(60, 2)}); err == nil {
+		t.Errorf("Calculate() should refuse kWh readings for a volume meter")
+	}
+	if _, err = NewDeltaCalculator(MeterConfig{Unit: "BTU"}); err == nil {
+		t.Errorf("NewDeltaCalculator() should refuse an unknown unit")
+	}
+}
@@ -0,0 +1,343 @@
+package energy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"github.com/safecility/go/lib/gbigquery"
+	"regexp"
+	"sort"
+	"time"
+)
+
+var ErrInvalidTariff = errors.New("invalid tariff")
+
+type DayType string
+
+const (
+	AllDays  DayType = ""
+	Weekdays DayType = "weekday"
+	Weekends DayType = "weekend"
+)
+
+func (d DayType) matches(w time.Weekday) bool {
+	weekend := w == time.Saturday || w == time.Sunday
+	switch d {
+	case Weekdays:
+		return !weekend
+	case Weekends:
+		return weekend
+	}
+	return true
+}
+
+// Band is a time of use rate between local clock times "HH:MM", a Start after End wraps past midnight
+// and an End of "24:00" is midnight
+type Band struct {
+	Name  string  `json:"name" firestore:"name"`
+	Days  DayType `json:"days,omitempty" firestore:"days"`
+	Start string  `json:"start" firestore:"start"`
+	End   string  `json:"end" firestore:"end"`
+	Rate  float64 `json:"rate" firestore:"rate"`
+
+	start, end int
+}
+
+var clockTime = regexp.MustCompile(`^([01]\d|2[0-4]):([0-5]\d)$`)
+
+func parseClock(s string) (int, error) {
+	m := clockTime.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("%w: clock time %q should be HH:MM", ErrInvalidTariff, s)
+	}
+	minutes := (int(m[1][0]-'0')*10+int(m[1][1]-'0'))*60 + int(m[2][0]-'0')*10 + int(m[2][1]-'0')
+	if minutes > 24*60 {
+		return 0, fmt.Errorf("%w: clock time %q is past midnight", ErrInvalidTariff, s)
+	}
+	return minutes, nil
+}
+
+// parse gives a copy of b with its clock times in minutes
+func (b Band) parse() (Band, error) {
+	var err error
+	if b.start, err = parseClock(b.Start); err != nil {
+		return Band{}, fmt.Errorf("band %s: %w", b.Name, err)
+	}
+	if b.end, err = parseClock(b.End); err != nil {
+		return Band{}, fmt.Errorf("band %s: %w", b.Name, err)
+	}
+	if b.start == b.end || b.start == 24*60 {
+		return Band{}, fmt.Errorf("%w: band %s from %s to %s", ErrInvalidTariff, b.Name, b.Start, b.End)
+	}
+	switch b.Days {
+	case AllDays, Weekdays, Weekends:
+	default:
+		return Band{}, fmt.Errorf("%w: band %s days %q", ErrInvalidTariff, b.Name, b.Days)
+	}
+	return b, nil
+}
+
+// parseBands parses bands into a new slice, leaving the caller's untouched
+func parseBands(bands []Band) ([]Band, error) {
+	if bands == nil {
+		return nil, nil
+	}
+	parsed := make([]Band, len(bands))
+	for i, b := range bands {
+		var err error
+		if parsed[i], err = b.parse(); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
+
+func (b *Band) contains(w time.Weekday, minute int) bool {
+	if !b.Days.matches(w) {
+		return false
+	}
+	if b.start < b.end {
+		return minute >= b.start && minute < b.end
+	}
+	return minute >= b.start || minute < b.end
+}
+
+// Season applies its bands during the months From to To inclusive, Nov to Feb wraps the year.
+// Rate is charged when none of the season's bands match.
+type Season struct {
+	Name  string     `json:"name" firestore:"name"`
+	From  time.Month `json:"from" firestore:"from"`
+	To    time.Month `json:"to" firestore:"to"`
+	Rate  float64    `json:"rate" firestore:"rate"`
+	Bands []Band     `json:"bands,omitempty" firestore:"bands"`
+}
+
+func (s Season) contains(m time.Month) bool {
+	if s.From <= s.To {
+		return m >= s.From && m <= s.To
+	}
+	return m >= s.From || m <= s.To
+}
+
+// Tier is a block rate for consumption up to UpTo within a calendar month, the last tier has no UpTo
+type Tier struct {
+	UpTo float64 `json:"upTo,omitempty" firestore:"upTo"`
+	Rate float64 `json:"rate" firestore:"rate"`
+}
+
+// Tariff prices consumption, rates are Currency per Unit and StandingCharge is Currency per day.
+// The energy rate comes from the first matching Season, otherwise from Bands and then Rate; tiered
+// tariffs use Tiers instead and can't have bands or seasons.
+// ID and Version are recorded on every CostResult so a bill can be traced back to the tariff used.
+type Tariff struct {
+	ID             string   `json:"id" firestore:"id"`
+	Version        string   `json:"version" firestore:"version"`
+	Currency       string   `json:"currency" firestore:"currency"`
+	Unit           lib.Unit `json:"unit,omitempty" firestore:"unit"`
+	TimeZone       string   `json:"timeZone,omitempty" firestore:"timeZone"`
+	Rate           float64  `json:"rate" firestore:"rate"`
+	Bands          []Band   `json:"bands,omitempty" firestore:"bands"`
+	Seasons        []Season `json:"seasons,omitempty" firestore:"seasons"`
+	Tiers          []Tier   `json:"tiers,omitempty" firestore:"tiers"`
+	StandingCharge float64  `json:"standingCharge,omitempty" firestore:"standingCharge"`
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Validate checks the tariff can be applied, it doesn't check that bands cover the whole day
+func (t Tariff) Validate() error {
+	if t.ID == "" || t.Version == "" {
+		return fmt.Errorf("%w: needs an id and version", ErrInvalidTariff)
+	}
+	if !currencyCode.MatchString(t.Currency) {
+		return fmt.Errorf("%w: currency %q is not an ISO 4217 code", ErrInvalidTariff, t.Currency)
+	}
+	if t.Unit != "" {
+		if _, err := t.Unit.Dimension(); err != nil {
+			return err
+		}
+	}
+	if _, err := time.LoadLocation(t.TimeZone); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTariff, err)
+	}
+	if _, err := parseBands(t.Bands); err != nil {
+		return err
+	}
+	for _, s := range t.Seasons {
+		if s.From < time.January || s.From > time.December || s.To < time.January || s.To > time.December {
+			return fmt.Errorf("%w: season %s months %d to %d", ErrInvalidTariff, s.Name, s.From, s.To)
+		}
+		if _, err := parseBands(s.Bands); err != nil {
+			return fmt.Errorf("season %s: %w", s.Name, err)
+		}
+	}
+	if len(t.Tiers) == 0 {
+		return nil
+	}
+	if len(t.Bands) > 0 || len(t.Seasons) > 0 {
+		return fmt.Errorf("%w: tiers can't be combined with bands or seasons", ErrInvalidTariff)
+	}
+	for i, tier := range t.Tiers {
+		last := i == len(t.Tiers)-1
+		switch {
+		case last && tier.UpTo != 0:
+			return fmt.Errorf("%w: the last tier can't have an upper limit", ErrInvalidTariff)
+		case !last && tier.UpTo <= 0:
+			return fmt.Errorf("%w: tier %d needs an upper limit", ErrInvalidTariff, i)
+		case i > 0 && !last && tier.UpTo <= t.Tiers[i-1].UpTo:
+			return fmt.Errorf("%w: tier limits must increase", ErrInvalidTariff)
+		}
+	}
+	return nil
+}
+
+// Cost of a bucket in the tariff Currency, Energy is the consumption charge and Standing the share of the
+// daily standing charge. Costs are not rounded.
+type Cost struct {
+	Bucket      gbigquery.Bucket `json:"bucket"`
+	Consumption lib.Quantity     `json:"consumption"`
+	Energy      float64          `json:"energy"`
+	Standing    float64          `json:"standing"`
+	Total       float64          `json:"total"`
+}
+
+type CostResult struct {
+	TariffID      string       `json:"tariffId"`
+	TariffVersion string       `json:"tariffVersion"`
+	Currency      string       `json:"currency"`
+	Costs         []Cost       `json:"costs"`
+	Consumption   lib.Quantity `json:"consumption"`
+	Energy        float64      `json:"energy"`
+	Standing      float64      `json:"standing"`
+	Total         float64      `json:"total"`
+}
+
+// CostCalculator applies a Tariff to consumption series
+type CostCalculator struct {
+	tariff   Tariff
+	location *time.Location
+}
+
+func NewCostCalculator(tariff Tariff) (*CostCalculator, error) {
+	if tariff.Unit == "" {
+		tariff.Unit = lib.KilowattHour
+	}
+	if err := tariff.Validate(); err != nil {
+		return nil, err
+	}
+	// the calculator keeps its own parsed bands, the caller's slices are never written
+	tariff.Bands, _ = parseBands(tariff.Bands)
+	seasons := make([]Season, len(tariff.Seasons))
+	for i, s := range tariff.Seasons {
+		s.Bands, _ = parseBands(s.Bands)
+		seasons[i] = s
+	}
+	tariff.Seasons = seasons
+	tariff.Tiers = append([]Tier(nil), tariff.Tiers...)
+	location, _ := time.LoadLocation(tariff.TimeZone)
+	return &CostCalculator{tariff: tariff, location: location}, nil
+}
+
+// Calculate prices each bucket of consumption, as from DeltaCalculator, in time order.
+// A bucket crossing band boundaries is priced assuming consumption was even across it.
+func (c *CostCalculator) Calculate(consumption []Consumption) (*CostResult, error) {
+	sorted := append([]Consumption(nil), consumption...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Bucket.StartTime.Before(sorted[j].Bucket.StartTime)
+	})
+	result := &CostResult{
+		TariffID:      c.tariff.ID,
+		TariffVersion: c.tariff.Version,
+		Currency:      c.tariff.Currency,
+		Costs:         make([]Cost, 0, len(sorted)),
+		Consumption:   lib.Quantity{Unit: c.tariff.Unit},
+	}
+	monthly := make(map[string]float64)
+	for _, b := range sorted {
+		q, err := b.Value.Convert(c.tariff.Unit)
+		if err != nil {
+			return nil, fmt.Errorf("bucket %s: %w", b.Bucket.StartTime, err)
+		}
+		if q.Value < 0 {
+			return nil, fmt.Errorf("bucket %s: negative consumption %s", b.Bucket.StartTime, q)
+		}
+		cost := Cost{Bucket: b.Bucket, Consumption: q}
+		if len(c.tariff.Tiers) > 0 {
+			month := b.Bucket.StartTime.In(c.location).Format("2006-01")
+			cost.Energy = c.tiered(monthly[month], q.Value)
+			monthly[month] += q.Value
+		} else {
+			cost.Energy = c.banded(b.Bucket, q.Value)
+		}
+		cost.Standing = c.tariff.StandingCharge * float64(b.Bucket.Duration) / float64(24*time.Hour)
+		cost.Total = cost.Energy + cost.Standing
+
+		result.Costs = append(result.Costs, cost)
+		result.Consumption.Value += q.Value
+		result.Energy += cost.Energy
+		result.Standing += cost.Standing
+		result.Total += cost.Total
+	}
+	return result, nil
+}
+
+// tiered prices value on top of the used consumption already in the month
+func (c *CostCalculator) tiered(used, value float64) float64 {
+	var cost, lower float64
+	for _, tier := range c.tariff.Tiers {
+		upper := tier.UpTo
+		if upper == 0 {
+			upper = used + value
+		}
+		from, to := max(used, lower), min(used+value, upper)
+		if to > from {
+			cost += (to - from) * tier.Rate
+		}
+		lower = upper
+	}
+	return cost
+}
+
+// banded walks the bucket from one rate change to the next sharing value in proportion to time
+func (c *CostCalculator) banded(b gbigquery.Bucket, value float64) float64 {
+	if value == 0 || b.Duration <= 0 {
+		return 0
+	}
+	var cost float64
+	end := b.End()
+	for t := b.StartTime; t.Before(end); {
+		rate, until := c.rateAt(t.In(c.location))
+		until = minTime(until, end)
+		cost += value * float64(until.Sub(t)) / float64(b.Duration) * rate
+		t = until
+	}
+	return cost
+}
+
+// rateAt gives the energy rate at local time t and when it may next change
+func (c *CostCalculator) rateAt(t time.Time) (float64, time.Time) {
+	rate, bands := c.tariff.Rate, c.tariff.Bands
+	for _, s := range c.tariff.Seasons {
+		if s.contains(t.Month()) {
+			rate, bands = s.Rate, s.Bands
+			break
+		}
+	}
+	y, m, d := t.Date()
+	minute := t.Hour()*60 + t.Minute()
+	next := time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+	for i := range bands {
+		for _, boundary := range []int{bands[i].start, bands[i].end} {
+			at := time.Date(y, m, d, 0, boundary, 0, 0, t.Location())
+			if boundary > minute && at.After(t) && at.Before(next) {
+				next = at
+			}
+		}
+	}
+	for i := range bands {
+		if bands[i].contains(t.Weekday(), minute) {
+			return bands[i].Rate, next
+		}
+	}
+	return rate, next
+}
@@ -0,0 +1,194 @@
+package energy
+
+import (
+	"errors"
+	"github.com/safecility/go/lib"
+	"github.com/safecility/go/lib/gbigquery"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func series(bt gbigquery.BucketType, from time.Time, values ...float64) []Consumption {
+	var consumption []Consumption
+	b := bt.Bucket(from)
+	for _, v := range values {
+		consumption = append(consumption, Consumption{Bucket: b, Value: lib.Quantity{Value: v, Unit: lib.KilowattHour}})
+		b = b.Next()
+	}
+	return consumption
+}
+
+func TestCostCalculator_Calculate(t *testing.T) {
+	hourly := gbigquery.BucketType{Interval: gbigquery.HOUR, Multiplier: 1}
+	daily := gbigquery.BucketType{Interval: gbigquery.DAY, Multiplier: 1}
+	// 2024-03-01 is a Friday
+	friday := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	saturday := friday.AddDate(0, 0, 1)
+	night := Band{Name: "night", Start: "23:00", End: "07:00", Rate: 0.1}
+
+	tests := []struct {
+		name        string
+		tariff      Tariff
+		consumption []Consumption
+		want        []float64
+		wantTotal   float64
+	}{
+		{
+			name:        "flat with standing charge",
+			tariff:      Tariff{Rate: 0.3, StandingCharge: 0.48},
+			consumption: series(hourly, friday, 1, 2),
+			want:        []float64{0.32, 0.62},
+			wantTotal:   0.94,
+		},
+		{
+			name:        "night band wraps midnight",
+			tariff:      Tariff{Rate: 0.3, Bands: []Band{night}},
+			consumption: series(hourly, friday.Add(22*time.Hour), 1, 1, 1),
+			want:        []float64{0.3, 0.1, 0.1},
+			wantTotal:   0.5,
+		},
+		{
+			name:        "bucket split at band boundary",
+			tariff:      Tariff{Rate: 0.3, Bands: []Band{{Name: "peak", Start: "16:30", End: "19:00", Rate: 0.5}}},
+			consumption: series(hourly, friday.Add(16*time.Hour), 2),
+			want:        []float64{0.8},
+			wantTotal:   0.8,
+		},
+		{
+			name: "weekend",
+			tariff: Tariff{Rate: 0.3, Bands: []Band{
+				{Name: "weekend", Days: Weekends, Start: "00:00", End: "24:00", Rate: 0.2},
+			}},
+			consumption: series(daily, friday, 10, 10),
+			want:        []float64{3, 2},
+			wantTotal:   5,
+		},
+		{
+			name: "seasons",
+			tariff: Tariff{Rate: 0.3, Seasons: []Season{
+				{Name: "winter", From: time.November, To: time.February, Rate: 0.4},
+				{Name: "summer", From: time.March, To: time.October, Rate: 0.25, Bands: []Band{night}},
+			}},
+			consumption: append(series(hourly, friday.Add(-time.Hour), 1, 1), series(hourly, friday.Add(7*time.Hour), 1)...),
+			want:        []float64{0.4, 0.1, 0.25},
+			wantTotal:   0.75,
+		},
+		{
+			name:        "local time zone",
+			tariff:      Tariff{Rate: 0.3, TimeZone: "Europe/Dublin", Bands: []Band{night}},
+			consumption: series(hourly, time.Date(2024, 7, 1, 5, 0, 0, 0, time.UTC), 1, 1),
+			want:        []float64{0.1, 0.3},
+			wantTotal:   0.4,
+		},
+		{
+			name:        "tiers reset monthly",
+			tariff:      Tariff{Tiers: []Tier{{UpTo: 100, Rate: 0.2}, {UpTo: 200, Rate: 0.3}, {Rate: 0.5}}},
+			consumption: append(series(daily, friday, 80, 80, 80), series(daily, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 50)...),
+			want:        []float64{16, 22, 32, 10},
+			wantTotal:   80,
+		},
+		{
+			name:        "saturday",
+			tariff:      Tariff{Rate: 0.3, Bands: []Band{{Name: "weekday", Days: Weekdays, Start: "08:00", End: "20:00", Rate: 0.4}}},
+			consumption: series(hourly, saturday.Add(12*time.Hour), 1),
+			want:        []float64{0.3},
+			wantTotal:   0.3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tariff.ID, tt.tariff.Version, tt.tariff.Currency = "t1", "2024-01", "EUR"
+			c, err := NewCostCalculator(tt.tariff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Calculate(tt.consumption)
+			if err != nil {
+				t.Fatalf("Calculate() error = %v", err)
+			}
+			if len(got.Costs) != len(tt.want) {
+				t.Fatalf("Calculate() = %v, want %v", got.Costs, tt.want)
+			}
+			for i, cost := range got.Costs {
+				if math.Abs(cost.Total-tt.want[i]) > 1e-9 {
+					t.Errorf("Costs[%d].Total = %v, want %v", i, cost.Total, tt.want[i])
+				}
+			}
+			if math.Abs(got.Total-tt.wantTotal) > 1e-9 {
+				t.Errorf("Total = %v, want %v", got.Total, tt.wantTotal)
+			}
+			if got.TariffID != "t1" || got.TariffVersion != "2024-01" || got.Currency != "EUR" {
+				t.Errorf("result doesn't record the tariff %s %s %s", got.TariffID, got.TariffVersion, got.Currency)
+			}
+		})
+	}
+}
+
+func TestCostCalculator_Units(t *testing.T) {
+	c, err := NewCostCalculator(Tariff{ID: "t1", Version: "1", Currency: "GBP", Unit: lib.MegawattHour, Rate: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumption := series(gbigquery.BucketType{Interval: gbigquery.HOUR}, time.Now(), 0)
+	consumption[0].Value = lib.Quantity{Value: 500, Unit: lib.KilowattHour}
+	got, err := c.Calculate(consumption)
+	if err != nil || math.Abs(got.Total-100) > 1e-9 {
+		t.Errorf("Calculate() = %v, %v", got, err)
+	}
+	consumption[0].Value = lib.Quantity{Value: 1, Unit: lib.CubicMetre}
+	if _, err = c.Calculate(consumption); !errors.Is(err, lib.ErrDimensionMismatch) {
+		t.Errorf("Calculate() error = %v, want ErrDimensionMismatch", err)
+	}
+}
+
+func TestTariff_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		tariff Tariff
+	}{
+		{name: "no version", tariff: Tariff{ID: "t1", Currency: "EUR"}},
+		{name: "currency", tariff: Tariff{ID: "t1", Version: "1", Currency: "euro"}},
+		{name: "time zone", tariff: Tariff{ID: "t1", Version: "1", Currency: "EUR", TimeZone: "Mars/Olympus"}},
+		{name: "band time", tariff: Tariff{ID: "t1", Version: "1", Currency: "EUR", Bands: []Band{{Start: "7:00", End: "09:00"}}}},
+		{name: "empty band", tariff: Tariff{ID: "t1", Version: "1", Currency: "EUR", Bands: []Band{{Start: "09:00", End: "09:00"}}}},
+		{name: "season month", tariff: Tariff{ID: "t1", Version: "1", Currency: "EUR", Seasons: []Season{{From: 0, To: time.March}}}},
+		{name: "tiers with bands", tariff: Tariff{ID: "t1", Version: "1", Currency: "EUR",
+			Bands: []Band{{Start: "07:00", End: "09:00"}}, Tiers: []Tier{{Rate: 1}}}},
+		{name: "unbounded tier", tariff: Tariff{ID: "t1", Version: "1", Currency: "EUR", Tiers: []Tier{{Rate: 1}, {UpTo: 10, Rate: 2}}}},
+		{name: "decreasing tiers", tariff: Tariff{ID: "t1", Version: "1", Currency: "EUR",
+			Tiers: []Tier{{UpTo: 10, Rate: 1}, {UpTo: 5, Rate: 2}, {Rate: 3}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCostCalculator(tt.tariff); !errors.Is(err, ErrInvalidTariff) {
+				t.Errorf("NewCostCalculator() error = %v, want ErrInvalidTariff", err)
+			}
+		})
+	}
+}
+
+func TestTariff_ValidateShared(t *testing.T) {
+	tariff := Tariff{ID: "t1", Version: "1", Currency: "EUR", Rate: 0.3,
+		Bands:   []Band{{Name: "night", Start: "23:00", End: "07:00", Rate: 0.1}},
+		Seasons: []Season{{Name: "winter", From: time.November, To: time.February, Bands: []Band{{Start: "16:00", End: "19:00"}}}},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := tariff.Validate(); err != nil {
+				t.Error(err)
+			}
+			if _, err := NewCostCalculator(tariff); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if tariff.Bands[0].start != 0 || tariff.Seasons[0].Bands[0].start != 0 {
+		t.Errorf("validating wrote parsed bands into the caller's tariff")
+	}
+}
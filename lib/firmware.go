@@ -0,0 +1,249 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidVersion is returned for firmware versions and constraints that can't be parsed
+var ErrInvalidVersion = errors.New("invalid version")
+
+// Version is a parsed semantic version, missing minor and patch numbers are 0
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Pre is the pre-release e.g. rc1, a version with a pre-release is below the same version without one
+	Pre string
+	// Build is build metadata e.g. 45 from "1.2 build 45", numeric builds order otherwise equal versions
+	Build string
+}
+
+// versionPattern accepts semver with an optional v prefix, a missing minor or patch, a pre-release with or without
+// the hyphen and build metadata as +45 or vendor style " build 45"
+var versionPattern = regexp.MustCompile(`^[vV]?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+)|([A-Za-z][0-9A-Za-z.]*))?(?:\+([0-9A-Za-z.-]+)|\s+(?i:build)\s+([0-9A-Za-z.-]+))?$`)
+
+func ParseVersion(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+	}
+	var v Version
+	for i, p := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return Version{}, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+		}
+		*p = n
+	}
+	v.Pre = m[4] + m[5]
+	v.Build = m[6] + m[7]
+	return v, nil
+}
+
+func MustParseVersion(s string) Version {
+	v, err := ParseVersion(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as v is below, equal to or above o
+func (v Version) Compare(o Version) int {
+	for _, d := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c := compareInts(d[0], d[1]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case v.Pre == "" && o.Pre != "":
+		return 1
+	case v.Pre != "" && o.Pre == "":
+		return -1
+	}
+	if c := comparePre(v.Pre, o.Pre); c != 0 {
+		return c
+	}
+	vb, vErr := strconv.Atoi(v.Build)
+	ob, oErr := strconv.Atoi(o.Build)
+	if vErr == nil && oErr == nil {
+		return compareInts(vb, ob)
+	}
+	return 0
+}
+
+func (v Version) Less(o Version) bool {
+	return v.Compare(o) < 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePre orders pre-releases by dot separated identifiers, numeric identifiers are below alphanumeric ones
+func comparePre(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareInts(an, bn)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(as), len(bs))
+}
+
+// ParsedVersion parses the FirmwareVersion
+func (f *Firmware) ParsedVersion() (Version, error) {
+	if f == nil {
+		return Version{}, fmt.Errorf("%w: no firmware", ErrInvalidVersion)
+	}
+	return ParseVersion(f.FirmwareVersion)
+}
+
+// Validate checks the firmware has a name and a parseable version
+func (f *Firmware) Validate() error {
+	if f == nil {
+		return fmt.Errorf("no firmware")
+	}
+	if f.FirmwareName == "" {
+		return fmt.Errorf("firmware needs a name")
+	}
+	_, err := f.ParsedVersion()
+	return err
+}
+
+// Below reports whether the firmware is below version, e.g. d.Firmware.Below("2.3.1")
+func (f *Firmware) Below(version string) (bool, error) {
+	v, err := f.ParsedVersion()
+	if err != nil {
+		return false, err
+	}
+	o, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	return v.Less(o), nil
+}
+
+// Satisfies reports whether the firmware version matches a constraint such as ">=1.2 <2.0"
+func (f *Firmware) Satisfies(constraint string) (bool, error) {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	v, err := f.ParsedVersion()
+	if err != nil {
+		return false, err
+	}
+	return c.Matches(v), nil
+}
+
+type versionClause struct {
+	op      string
+	version Version
+}
+
+// Constraint is a set of version comparisons that must all hold e.g. ">=1.2 <2.0" or ">=1.2, !=1.4.1"
+type Constraint struct {
+	clauses []versionClause
+	text    string
+}
+
+var constraintOps = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{text: strings.TrimSpace(s)}
+	fields := strings.Fields(strings.ReplaceAll(s, ",", " "))
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		op := "="
+		for _, o := range constraintOps {
+			if strings.HasPrefix(field, o) {
+				op, field = o, strings.TrimPrefix(field, o)
+				break
+			}
+		}
+		// allow a space between the operator and version as in ">= 1.2"
+		if field == "" && i+1 < len(fields) {
+			i++
+			field = fields[i]
+		}
+		v, err := ParseVersion(field)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("%w: constraint %q", ErrInvalidVersion, s)
+		}
+		if op == "==" {
+			op = "="
+		}
+		c.clauses = append(c.clauses, versionClause{op: op, version: v})
+	}
+	if len(c.clauses) == 0 {
+		return Constraint{}, fmt.Errorf("%w: empty constraint", ErrInvalidVersion)
+	}
+	return c, nil
+}
+
+func (c Constraint) Matches(v Version) bool {
+	for _, clause := range c.clauses {
+		r := v.Compare(clause.version)
+		var ok bool
+		switch clause.op {
+		case ">=":
+			ok = r >= 0
+		case "<=":
+			ok = r <= 0
+		case ">":
+			ok = r > 0
+		case "<":
+			ok = r < 0
+		case "!=":
+			ok = r != 0
+		default:
+			ok = r == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Constraint) String() string {
+	return c.text
+}
@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Version
+		wantErr bool
+	}{
+		{name: "semver", s: "1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{name: "prefix and pre-release", s: "v1.2.3-rc1", want: Version{Major: 1, Minor: 2, Patch: 3, Pre: "rc1"}},
+		{name: "pre-release without hyphen", s: "2.0beta.2", want: Version{Major: 2, Pre: "beta.2"}},
+		{name: "vendor build", s: "1.2 build 45", want: Version{Major: 1, Minor: 2, Build: "45"}},
+		{name: "semver build", s: "1.2.3-rc.1+exp.sha", want: Version{Major: 1, Minor: 2, Patch: 3, Pre: "rc.1", Build: "exp.sha"}},
+		{name: "major only", s: "7", want: Version{Major: 7}},
+		{name: "empty", s: "", wantErr: true},
+		{name: "words", s: "latest", wantErr: true},
+		{name: "trailing dot", s: "1.2.", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVersion(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidVersion) {
+				t.Errorf("ParseVersion() error = %v, want ErrInvalidVersion", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseVersion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion_Compare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "1.10", b: "1.9", want: 1},
+		{a: "1.2.3-rc1", b: "1.2.3", want: -1},
+		{a: "1.2.3-rc.2", b: "1.2.3-rc.10", want: -1},
+		{a: "1.2.3-alpha", b: "1.2.3-1", want: 1},
+		{a: "1.2.3-rc", b: "1.2.3-rc.1", want: -1},
+		{a: "1.2 build 45", b: "1.2 build 46", want: -1},
+		{a: "1.2+abc", b: "1.2+def", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			if got := MustParseVersion(tt.a).Compare(MustParseVersion(tt.b)); got != tt.want {
+				t.Errorf("Compare() = %d, want %d", got, tt.want)
+			}
+			if got := MustParseVersion(tt.b).Compare(MustParseVersion(tt.a)); got != -tt.want {
+				t.Errorf("reversed Compare() = %d, want %d", got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestFirmware_Satisfies(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    string
+		constraint string
+		want       bool
+		wantErr    bool
+	}{
+		{name: "in range", version: "1.5", constraint: ">=1.2 <2.0", want: true},
+		{name: "at lower bound", version: "1.2.0", constraint: ">=1.2 <2.0", want: true},
+		{name: "at upper bound", version: "2.0", constraint: ">=1.2 <2.0"},
+		{name: "pre-release below bound", version: "2.0.0-rc1", constraint: ">=1.2, <2.0", want: true},
+		{name: "excluded", version: "1.4.1", constraint: ">= 1.2, != 1.4.1"},
+		{name: "exact", version: "v3.0.0", constraint: "3.0", want: true},
+		{name: "bad constraint", version: "1.0", constraint: ">=one", wantErr: true},
+		{name: "empty constraint", version: "1.0", constraint: " ", wantErr: true},
+		{name: "bad version", version: "unknown", constraint: ">=1.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Firmware{FirmwareName: "pm", FirmwareVersion: tt.version}
+			got, err := f.Satisfies(tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Satisfies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Satisfies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirmware_Below(t *testing.T) {
+	f := &Firmware{FirmwareName: "pm", FirmwareVersion: "2.3.0"}
+	if below, err := f.Below("2.3.1"); err != nil || !below {
+		t.Errorf("Below() = %v, %v", below, err)
+	}
+	if err := (&Firmware{FirmwareName: "pm", FirmwareVersion: "x"}).Validate(); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("Validate() error = %v, want ErrInvalidVersion", err)
+	}
+	if err := f.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
@@ -40,3 +40,44 @@ type Bucket struct {
 	StartTime time.Time     `datastore:"Time"`
 	Duration  time.Duration `datastore:"-"`
 }
+
+// Duration of a bucket, a Multiplier below 1 counts as 1 and days are 24 hours
+func (bt BucketType) Duration() time.Duration {
+	m := bt.Multiplier
+	if m < 1 {
+		m = 1
+	}
+	switch bt.Interval {
+	case DAY:
+		return time.Duration(m) * 24 * time.Hour
+	default:
+		return time.Duration(m) * time.Hour
+	}
+}
+
+// Bucket gives the UTC aligned bucket holding t
+func (bt BucketType) Bucket(t time.Time) Bucket {
+	d := bt.Duration()
+	return Bucket{StartTime: t.UTC().Truncate(d), Duration: d}
+}
+
+// Buckets lists the buckets covering the interval, the last bucket holds any time before End
+func (bt BucketType) Buckets(qi QueryInterval) []Bucket {
+	var buckets []Bucket
+	for b := bt.Bucket(qi.Start); b.StartTime.Before(qi.End); b = b.Next() {
+		buckets = append(buckets, b)
+	}
+	return buckets
+}
+
+func (b Bucket) End() time.Time {
+	return b.StartTime.Add(b.Duration)
+}
+
+func (b Bucket) Next() Bucket {
+	return Bucket{StartTime: b.End(), Duration: b.Duration}
+}
+
+func (b Bucket) Contains(t time.Time) bool {
+	return !t.Before(b.StartTime) && t.Before(b.End())
+}
@@ -10,6 +10,11 @@ import (
 
 // CreateProtoSchema creates a schema resource from a schema proto file.
 func CreateProtoSchema(client *pubsub.SchemaClient, schemaID, protoFile string) (*pubsub.SchemaConfig, error) {
+	return CreateProtoSchemaContext(context.Background(), client, schemaID, protoFile)
+}
+
+// CreateProtoSchemaContext is CreateProtoSchema bounded by ctx
+func CreateProtoSchemaContext(ctx context.Context, client *pubsub.SchemaClient, schemaID, protoFile string) (*pubsub.SchemaConfig, error) {
 	protoSource, err := os.ReadFile(protoFile)
 	if err != nil {
 		return nil, fmt.Errorf("error reading from file: %s", protoFile)
@@ -20,7 +25,6 @@ func CreateProtoSchema(client *pubsub.SchemaClient, schemaID, protoFile string)
 		Definition: string(protoSource),
 	}
 
-	ctx := context.Background()
 	s, err := client.CreateSchema(ctx, schemaID, config)
 	if err != nil {
 		return nil, err
@@ -31,6 +35,11 @@ func CreateProtoSchema(client *pubsub.SchemaClient, schemaID, protoFile string)
 
 // UpdateProtoSchema creates a schema resource from a schema proto file.
 func UpdateProtoSchema(client *pubsub.SchemaClient, name, revisionID, protoFile string) (*pubsub.SchemaConfig, error) {
+	return UpdateProtoSchemaContext(context.Background(), client, name, revisionID, protoFile)
+}
+
+// UpdateProtoSchemaContext is UpdateProtoSchema bounded by ctx
+func UpdateProtoSchemaContext(ctx context.Context, client *pubsub.SchemaClient, name, revisionID, protoFile string) (*pubsub.SchemaConfig, error) {
 	protoSource, err := os.ReadFile(protoFile)
 	if err != nil {
 		return nil, fmt.Errorf("error reading from file: %s", protoFile)
@@ -43,7 +52,6 @@ func UpdateProtoSchema(client *pubsub.SchemaClient, name, revisionID, protoFile
 		RevisionID: revisionID,
 	}
 
-	ctx := context.Background()
 	s, err := client.CommitSchema(ctx, name, config)
 	if err != nil {
 		return nil, err
@@ -53,7 +61,16 @@ func UpdateProtoSchema(client *pubsub.SchemaClient, name, revisionID, protoFile
 }
 
 func CreateBigqueryTopic(client *pubsub.Client, topicName string, schema *pubsub.SchemaConfig) (*pubsub.Topic, error) {
-	ctx := context.Background()
+	bigqueryTopic, err := CreateBigqueryTopicContext(context.Background(), client, topicName, schema)
+	if err != nil {
+		log.Fatal().Err(err).Msg("setup could not create topic")
+	}
+	return bigqueryTopic, nil
+}
+
+// CreateBigqueryTopicContext is CreateBigqueryTopic bounded by ctx, unlike CreateBigqueryTopic it returns
+// the error rather than exiting
+func CreateBigqueryTopicContext(ctx context.Context, client *pubsub.Client, topicName string, schema *pubsub.SchemaConfig) (*pubsub.Topic, error) {
 	bigqueryTopic, err := client.CreateTopicWithConfig(ctx, topicName, &pubsub.TopicConfig{
 		SchemaSettings: &pubsub.SchemaSettings{
 			Schema:   schema.Name,
@@ -61,7 +78,7 @@ func CreateBigqueryTopic(client *pubsub.Client, topicName string, schema *pubsub
 		},
 	})
 	if err != nil {
-		log.Fatal().Err(err).Msg("setup could not create topic")
+		return nil, err
 	}
 	log.Info().Str("topic", bigqueryTopic.String()).Msg("created topic")
 
@@ -70,8 +87,11 @@ func CreateBigqueryTopic(client *pubsub.Client, topicName string, schema *pubsub
 
 // CreateBigQuerySubscription creates a Pub/Sub subscription that exports messages to BigQuery.
 func CreateBigQuerySubscription(client *pubsub.Client, subscriptionName, table string, topic *pubsub.Topic) error {
-	ctx := context.Background()
+	return CreateBigQuerySubscriptionContext(context.Background(), client, subscriptionName, table, topic)
+}
 
+// CreateBigQuerySubscriptionContext is CreateBigQuerySubscription bounded by ctx
+func CreateBigQuerySubscriptionContext(ctx context.Context, client *pubsub.Client, subscriptionName, table string, topic *pubsub.Topic) error {
 	sub, err := client.CreateSubscription(ctx, subscriptionName, pubsub.SubscriptionConfig{
 		Topic: topic,
 		BigQueryConfig: pubsub.BigQueryConfig{
@@ -25,11 +25,17 @@ func NewBQTable(client *bigquery.Client) *BQTable {
 }
 
 func (bqt BQTable) CheckOrCreateBigqueryTable(config *BQTableConfig, metaData *bigquery.TableMetadata) (*bigquery.TableMetadata, error) {
-	ctx := context.Background()
+	return bqt.CheckOrCreateBigqueryTableContext(context.Background(), config, metaData)
+}
 
+// CheckOrCreateBigqueryTableContext is CheckOrCreateBigqueryTable bounded by ctx
+func (bqt BQTable) CheckOrCreateBigqueryTableContext(ctx context.Context, config *BQTableConfig, metaData *bigquery.TableMetadata) (*bigquery.TableMetadata, error) {
 	tableRef := bqt.client.Dataset(config.Dataset).Table(config.Table)
 
 	tableMetadata, err := tableRef.Metadata(ctx)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get table metadata")
 	}
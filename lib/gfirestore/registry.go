@@ -0,0 +1,176 @@
+package gfirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultDeviceCollection is where devices are stored unless the registry is given another collection
+const DefaultDeviceCollection = "devices"
+
+// DeviceRegistry is the firestore lib.DeviceRegistry, each device is a document keyed by its DeviceUID
+// using the firestore tags on lib.Device and lib.DeviceMeta.
+// Tag and list queries need composite indexes on companyUID/tag and on the fields used together in a lib.DeviceQuery.
+type DeviceRegistry struct {
+	client     *firestore.Client
+	collection string
+}
+
+var _ lib.DeviceRegistry = (*DeviceRegistry)(nil)
+
+func NewDeviceRegistry(client *firestore.Client, collection string) *DeviceRegistry {
+	if collection == "" {
+		collection = DefaultDeviceCollection
+	}
+	return &DeviceRegistry{client: client, collection: collection}
+}
+
+func (r *DeviceRegistry) devices() *firestore.CollectionRef {
+	return r.client.Collection(r.collection)
+}
+
+func (r *DeviceRegistry) GetDevice(ctx context.Context, uid string) (*lib.Device, error) {
+	doc, err := r.devices().Doc(uid).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("%w: %s", lib.ErrDeviceNotFound, uid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get device %s: %w", uid, err)
+	}
+	return toDevice(doc)
+}
+
+func (r *DeviceRegistry) tagQuery(companyUID, tag string) firestore.Query {
+	return r.devices().Where("companyUID", "==", companyUID).Where("tag", "==", tag).Limit(2)
+}
+
+func (r *DeviceRegistry) GetDeviceByTag(ctx context.Context, companyUID, tag string) (*lib.Device, error) {
+	docs, err := r.tagQuery(companyUID, tag).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not query tag %s: %w", tag, err)
+	}
+	switch len(docs) {
+	case 0:
+		return nil, fmt.Errorf("%w: tag %s in company %s", lib.ErrDeviceNotFound, tag, companyUID)
+	case 1:
+		return toDevice(docs[0])
+	default:
+		return nil, fmt.Errorf("%w: tag %s held by %s and %s", lib.ErrDeviceConflict, tag, docs[0].Ref.ID, docs[1].Ref.ID)
+	}
+}
+
+func (r *DeviceRegistry) ListDevices(ctx context.Context, query lib.DeviceQuery) ([]*lib.Device, error) {
+	q := r.devices().Query
+	if query.CompanyUID != "" {
+		q = q.Where("companyUID", "==", query.CompanyUID)
+	}
+	if query.LocationUID != "" {
+		q = q.Where("locationUID", "==", query.LocationUID)
+	}
+	if query.DeviceType != "" {
+		q = q.Where("type", "==", string(query.DeviceType))
+	}
+
+	iter := q.OrderBy(firestore.DocumentID, firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+	var devices []*lib.Device
+	for {
+		doc, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not list devices: %w", err)
+		}
+		d, err := toDevice(doc)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// UpsertDevice writes d in a transaction that refuses to give its DeviceTag to a second device in the company
+func (r *DeviceRegistry) UpsertDevice(ctx context.Context, d *lib.Device) error {
+	if d == nil || d.DeviceUID == "" {
+		return fmt.Errorf("device needs a uid")
+	}
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		return r.set(tx, d)
+	})
+}
+
+// UpdateDevice applies update in a transaction, firestore retries it if the device changes before the commit
+func (r *DeviceRegistry) UpdateDevice(ctx context.Context, uid string, update func(d *lib.Device) error) (*lib.Device, error) {
+	var updated *lib.Device
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(r.devices().Doc(uid))
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("%w: %s", lib.ErrDeviceNotFound, uid)
+		}
+		if err != nil {
+			return fmt.Errorf("could not get device %s: %w", uid, err)
+		}
+		d, err := toDevice(doc)
+		if err != nil {
+			return err
+		}
+		if err = update(d); err != nil {
+			return err
+		}
+		if d.DeviceUID != uid {
+			return fmt.Errorf("update can't change device %s's uid", uid)
+		}
+		updated = d
+		return r.set(tx, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// set writes d in tx, refusing to give its DeviceTag to a second device in the company
+func (r *DeviceRegistry) set(tx *firestore.Transaction, d *lib.Device) error {
+	if d.DeviceMeta != nil && d.DeviceTag != "" {
+		docs, err := tx.Documents(r.tagQuery(d.CompanyUID, d.DeviceTag)).GetAll()
+		if err != nil {
+			return fmt.Errorf("could not query tag %s: %w", d.DeviceTag, err)
+		}
+		for _, doc := range docs {
+			if doc.Ref.ID != d.DeviceUID {
+				return fmt.Errorf("%w: tag %s is held by %s", lib.ErrDeviceConflict, d.DeviceTag, doc.Ref.ID)
+			}
+		}
+	}
+	return tx.Set(r.devices().Doc(d.DeviceUID), d)
+}
+
+func (r *DeviceRegistry) DeleteDevice(ctx context.Context, uid string) error {
+	_, err := r.devices().Doc(uid).Delete(ctx, firestore.Exists)
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("%w: %s", lib.ErrDeviceNotFound, uid)
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete device %s: %w", uid, err)
+	}
+	return nil
+}
+
+func toDevice(doc *firestore.DocumentSnapshot) (*lib.Device, error) {
+	d := &lib.Device{}
+	if err := doc.DataTo(d); err != nil {
+		return nil, fmt.Errorf("could not read device %s: %w", doc.Ref.ID, err)
+	}
+	if d.DeviceUID == "" {
+		d.DeviceUID = doc.Ref.ID
+	}
+	return d, nil
+}
@@ -0,0 +1,77 @@
+package gfirestore
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/url"
+)
+
+// DefaultTagHistoryCollection is where tag histories are stored unless the TagHistory is given another collection
+const DefaultTagHistoryCollection = "tagHistory"
+
+// TagHistory is the firestore lib.TagHistory, each company tag is one document holding its assignments
+type TagHistory struct {
+	client     *firestore.Client
+	collection string
+}
+
+var _ lib.TagHistory = (*TagHistory)(nil)
+
+type tagHistoryDoc struct {
+	CompanyUID  string              `firestore:"companyUID"`
+	DeviceTag   string              `firestore:"tag"`
+	Assignments []lib.TagAssignment `firestore:"assignments"`
+}
+
+func NewTagHistory(client *firestore.Client, collection string) *TagHistory {
+	if collection == "" {
+		collection = DefaultTagHistoryCollection
+	}
+	return &TagHistory{client: client, collection: collection}
+}
+
+// doc ids can't hold a slash so tags are escaped
+func (h *TagHistory) doc(companyUID, tag string) *firestore.DocumentRef {
+	return h.client.Collection(h.collection).Doc(url.PathEscape(companyUID) + ":" + url.PathEscape(tag))
+}
+
+func (h *TagHistory) Record(ctx context.Context, a lib.TagAssignment) error {
+	ref := h.doc(a.CompanyUID, a.DeviceTag)
+	return h.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		current := tagHistoryDoc{CompanyUID: a.CompanyUID, DeviceTag: a.DeviceTag}
+		snap, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("could not get tag history %s: %w", a.DeviceTag, err)
+		}
+		if err == nil {
+			if err = snap.DataTo(&current); err != nil {
+				return fmt.Errorf("could not read tag history %s: %w", a.DeviceTag, err)
+			}
+		}
+		next, err := lib.NextAssignments(current.Assignments, a)
+		if err != nil {
+			return err
+		}
+		current.Assignments = next
+		return tx.Set(ref, current)
+	})
+}
+
+func (h *TagHistory) History(ctx context.Context, companyUID, tag string) ([]lib.TagAssignment, error) {
+	snap, err := h.doc(companyUID, tag).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get tag history %s: %w", tag, err)
+	}
+	var current tagHistoryDoc
+	if err = snap.DataTo(&current); err != nil {
+		return nil, fmt.Errorf("could not read tag history %s: %w", tag, err)
+	}
+	return current.Assignments, nil
+}
@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrInvalidTransition is returned for lifecycle changes that aren't allowed from the device's current state
+var ErrInvalidTransition = errors.New("invalid lifecycle transition")
+
+type LifecycleState string
+
+const (
+	Provisioned    LifecycleState = "provisioned"
+	Commissioned   LifecycleState = "commissioned"
+	Active         LifecycleState = "active"
+	Maintenance    LifecycleState = "maintenance"
+	Faulty         LifecycleState = "faulty"
+	Decommissioned LifecycleState = "decommissioned"
+)
+
+// transitions lists the states each state can move to, decommissioned is final
+var transitions = map[LifecycleState][]LifecycleState{
+	Provisioned:  {Commissioned, Decommissioned},
+	Commissioned: {Active, Faulty, Decommissioned},
+	Active:       {Maintenance, Faulty, Decommissioned},
+	Maintenance:  {Active, Faulty, Decommissioned},
+	Faulty:       {Maintenance, Active, Decommissioned},
+}
+
+// effective treats an empty state as Active for devices stored before lifecycles
+func (s LifecycleState) effective() LifecycleState {
+	if s == "" {
+		return Active
+	}
+	return s
+}
+
+func (s LifecycleState) Valid() bool {
+	_, ok := transitions[s]
+	return ok || s == Decommissioned
+}
+
+// Reporting reports whether readings from a device in this state belong in analytics
+func (s LifecycleState) Reporting() bool {
+	return s.effective() == Active
+}
+
+// CanTransition reports whether a device can move from one state to another
+func CanTransition(from, to LifecycleState) bool {
+	for _, allowed := range transitions[from.effective()] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// LifecycleTransition records a change of a device's state
+type LifecycleTransition struct {
+	From   LifecycleState `firestore:"from,omitempty" json:"from,omitempty"`
+	To     LifecycleState `firestore:"to" json:"to"`
+	At     time.Time      `firestore:"at" json:"at"`
+	Reason string         `firestore:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// MaxStatusHistory is how many transitions DeviceMeta.StatusHistory keeps, older ones are dropped as the meta travels
+// with every enriched message
+const MaxStatusHistory = 16
+
+// TransitionHook is called after a transition is stored, d holds the device's new state
+type TransitionHook func(ctx context.Context, d *Device, t LifecycleTransition)
+
+// Lifecycle moves devices in a DeviceRegistry between states, recording each transition on the device's StatusHistory
+type Lifecycle struct {
+	registry DeviceRegistry
+	mu       sync.RWMutex
+	hooks    []TransitionHook
+}
+
+func NewLifecycle(registry DeviceRegistry) *Lifecycle {
+	return &Lifecycle{registry: registry}
+}
+
+// OnTransition adds a hook run after every transition in the order added
+func (l *Lifecycle) OnTransition(hook TransitionHook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// Transition moves a device to state to, returning an error wrapping ErrInvalidTransition if it isn't allowed.
+// The check and write are one UpdateDevice so concurrent transitions can't both pass from the same state.
+func (l *Lifecycle) Transition(ctx context.Context, uid string, to LifecycleState, reason string, at time.Time) (*Device, error) {
+	if !to.Valid() {
+		return nil, fmt.Errorf("%w: unknown state %s", ErrInvalidTransition, to)
+	}
+	var t LifecycleTransition
+	d, err := l.registry.UpdateDevice(ctx, uid, func(d *Device) error {
+		if d.DeviceMeta == nil {
+			d.DeviceMeta = &DeviceMeta{}
+		}
+		from := d.Status.effective()
+		if !CanTransition(from, to) {
+			return fmt.Errorf("%w: %s from %s to %s", ErrInvalidTransition, uid, from, to)
+		}
+		t = LifecycleTransition{From: from, To: to, At: at, Reason: reason}
+		d.Status = to
+		d.StatusHistory = append(d.StatusHistory, t)
+		if n := len(d.StatusHistory); n > MaxStatusHistory {
+			d.StatusHistory = append([]LifecycleTransition(nil), d.StatusHistory[n-MaxStatusHistory:]...)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrDeviceNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not store %s transition: %w", uid, err)
+	}
+
+	l.mu.RLock()
+	hooks := l.hooks
+	l.mu.RUnlock()
+	for _, hook := range hooks {
+		hook(ctx, d, t)
+	}
+	return d, nil
+}
+
+// StatusAt gives the device's state at t from its StatusHistory, the empty state before the oldest transition kept
+func (d *DeviceMeta) StatusAt(t time.Time) LifecycleState {
+	var state LifecycleState
+	for _, transition := range d.StatusHistory {
+		if transition.At.After(t) {
+			break
+		}
+		state = transition.To
+	}
+	return state
+}
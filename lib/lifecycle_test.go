@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to LifecycleState
+		want     bool
+	}{
+		{from: Provisioned, to: Commissioned, want: true},
+		{from: Provisioned, to: Active},
+		{from: Active, to: Maintenance, want: true},
+		{from: Faulty, to: Active, want: true},
+		{from: "", to: Maintenance, want: true},
+		{from: Decommissioned, to: Active},
+		{from: Active, to: "retired"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLifecycle_Transition(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryDeviceRegistry()
+	_ = registry.UpsertDevice(ctx, &Device{DeviceUID: "a", DeviceMeta: &DeviceMeta{Status: Provisioned}})
+	l := NewLifecycle(registry)
+
+	var fired []LifecycleTransition
+	l.OnTransition(func(ctx context.Context, d *Device, t LifecycleTransition) {
+		fired = append(fired, t)
+	})
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		to      LifecycleState
+		wantErr error
+	}{
+		{to: Active, wantErr: ErrInvalidTransition},
+		{to: Commissioned},
+		{to: Active},
+		{to: Faulty},
+		{to: Decommissioned},
+		{to: Active, wantErr: ErrInvalidTransition},
+	}
+	for i, step := range steps {
+		_, err := l.Transition(ctx, "a", step.to, "step", start.Add(time.Duration(i)*time.Hour))
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("Transition(%s) error = %v, want %v", step.to, err, step.wantErr)
+		}
+	}
+
+	d, _ := registry.GetDevice(ctx, "a")
+	if d.Status != Decommissioned || len(d.StatusHistory) != 4 || len(fired) != 4 {
+		t.Fatalf("status %s with %d transitions and %d hooks fired", d.Status, len(d.StatusHistory), len(fired))
+	}
+	if fired[1].From != Commissioned || fired[1].To != Active {
+		t.Errorf("hook got %+v", fired[1])
+	}
+	if got := d.StatusAt(start.Add(150 * time.Minute)); got != Active {
+		t.Errorf("StatusAt() = %s, want active", got)
+	}
+	if got := d.StatusAt(start); got != "" {
+		t.Errorf("StatusAt() before the first transition = %s", got)
+	}
+	if _, err := l.Transition(ctx, "missing", Active, "", start); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("Transition() error = %v, want ErrDeviceNotFound", err)
+	}
+}
+
+func TestLifecycle_ConcurrentTransitions(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryDeviceRegistry()
+	_ = registry.UpsertDevice(ctx, &Device{DeviceUID: "a", DeviceMeta: &DeviceMeta{Status: Provisioned}})
+	l := NewLifecycle(registry)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = l.Transition(ctx, "a", Commissioned, "", time.Now())
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else if !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("Transition() error = %v, want ErrInvalidTransition", err)
+		}
+	}
+	d, _ := registry.GetDevice(ctx, "a")
+	if succeeded != 1 || len(d.StatusHistory) != 1 {
+		t.Errorf("%d transitions succeeded with %d recorded, want 1", succeeded, len(d.StatusHistory))
+	}
+}
+
+func TestLifecycle_HistoryCapped(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryDeviceRegistry()
+	_ = registry.UpsertDevice(ctx, &Device{DeviceUID: "a", DeviceMeta: &DeviceMeta{Status: Active}})
+	l := NewLifecycle(registry)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	states := []LifecycleState{Maintenance, Active}
+	for i := 0; i < MaxStatusHistory+3; i++ {
+		if _, err := l.Transition(ctx, "a", states[i%2], "", start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, _ := registry.GetDevice(ctx, "a")
+	if len(d.StatusHistory) != MaxStatusHistory || !d.StatusHistory[0].At.Equal(start.Add(3*time.Hour)) {
+		t.Errorf("history holds %d transitions from %s", len(d.StatusHistory), d.StatusHistory[0].At)
+	}
+}
@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrLocationNotFound = errors.New("location not found")
+	// ErrLocationCycle is returned when parent links would make a location its own ancestor
+	ErrLocationCycle = errors.New("location cycle")
+)
+
+type LocationKind string
+
+const (
+	Estate   LocationKind = "estate"
+	Building LocationKind = "building"
+	Floor    LocationKind = "floor"
+	Room     LocationKind = "room"
+)
+
+// Location is a node in a company's location tree, a DeviceMeta LocationUID refers to a Location's UID.
+// An empty ParentUID makes the location a root.
+type Location struct {
+	UID        string       `firestore:"uid" json:"uid"`
+	CompanyUID string       `firestore:"companyUID" json:"companyUID"`
+	ParentUID  string       `firestore:"parentUID,omitempty" json:"parentUID,omitempty"`
+	Name       string       `firestore:"name,omitempty" json:"name,omitempty"`
+	Kind       LocationKind `firestore:"kind,omitempty" json:"kind,omitempty"`
+}
+
+// LocationTree holds locations by parent link for path queries and rolling readings up to ancestors
+type LocationTree struct {
+	mu        sync.RWMutex
+	locations map[string]Location
+	children  map[string][]string
+}
+
+// NewLocationTree builds a tree checking every parent exists, is in the same company and that there are no cycles
+func NewLocationTree(locations []Location) (*LocationTree, error) {
+	t := &LocationTree{
+		locations: make(map[string]Location, len(locations)),
+		children:  make(map[string][]string),
+	}
+	for _, l := range locations {
+		if l.UID == "" {
+			return nil, fmt.Errorf("location needs a uid")
+		}
+		if _, ok := t.locations[l.UID]; ok {
+			return nil, fmt.Errorf("location %s is duplicated", l.UID)
+		}
+		t.locations[l.UID] = l
+	}
+	var errs []error
+	for _, l := range t.locations {
+		if err := t.checkParent(l); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, l := range t.locations {
+		t.link(l)
+	}
+	return t, nil
+}
+
+func (t *LocationTree) checkParent(l Location) error {
+	if l.ParentUID == "" {
+		return nil
+	}
+	parent, ok := t.locations[l.ParentUID]
+	if !ok {
+		return fmt.Errorf("%w: parent %s of %s", ErrLocationNotFound, l.ParentUID, l.UID)
+	}
+	if parent.CompanyUID != l.CompanyUID {
+		return fmt.Errorf("location %s in company %s has parent %s in company %s", l.UID, l.CompanyUID, parent.UID, parent.CompanyUID)
+	}
+	seen := map[string]bool{l.UID: true}
+	for uid := l.ParentUID; uid != ""; uid = t.locations[uid].ParentUID {
+		if seen[uid] {
+			return fmt.Errorf("%w: %s is its own ancestor", ErrLocationCycle, l.UID)
+		}
+		seen[uid] = true
+	}
+	return nil
+}
+
+func (t *LocationTree) link(l Location) {
+	if l.ParentUID == "" {
+		return
+	}
+	children := append(t.children[l.ParentUID], l.UID)
+	sort.Strings(children)
+	t.children[l.ParentUID] = children
+}
+
+func (t *LocationTree) unlink(l Location) {
+	children := t.children[l.ParentUID]
+	for i, uid := range children {
+		if uid == l.UID {
+			t.children[l.ParentUID] = append(children[:i:i], children[i+1:]...)
+			return
+		}
+	}
+}
+
+// Put adds or moves a location, refusing a parent that is missing or would create a cycle
+func (t *LocationTree) Put(l Location) error {
+	if l.UID == "" {
+		return fmt.Errorf("location needs a uid")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous, exists := t.locations[l.UID]
+	t.locations[l.UID] = l
+	if err := t.checkParent(l); err != nil {
+		if exists {
+			t.locations[l.UID] = previous
+		} else {
+			delete(t.locations, l.UID)
+		}
+		return err
+	}
+	if exists {
+		t.unlink(previous)
+	}
+	t.link(l)
+	return nil
+}
+
+func (t *LocationTree) Get(uid string) (Location, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	l, ok := t.locations[uid]
+	if !ok {
+		return Location{}, fmt.Errorf("%w: %s", ErrLocationNotFound, uid)
+	}
+	return l, nil
+}
+
+// Path gives the locations from the root down to uid
+func (t *LocationTree) Path(uid string) ([]Location, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var path []Location
+	for next := uid; next != ""; {
+		l, ok := t.locations[next]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrLocationNotFound, next)
+		}
+		path = append([]Location{l}, path...)
+		next = l.ParentUID
+	}
+	return path, nil
+}
+
+func (t *LocationTree) Children(uid string) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	children := make([]string, len(t.children[uid]))
+	copy(children, t.children[uid])
+	return children
+}
+
+// Descendants lists uid and every location under it, parents before children
+func (t *LocationTree) Descendants(uid string) ([]string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if _, ok := t.locations[uid]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrLocationNotFound, uid)
+	}
+	descendants := []string{uid}
+	for i := 0; i < len(descendants); i++ {
+		descendants = append(descendants, t.children[descendants[i]]...)
+	}
+	return descendants, nil
+}
+
+// RollUp adds each location's total to every ancestor, giving the total under each location.
+// Totals for locations not in the tree are skipped.
+func (t *LocationTree) RollUp(totals map[string]float64) map[string]float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	rolled := make(map[string]float64)
+	for uid, v := range totals {
+		for next := uid; next != ""; next = t.locations[next].ParentUID {
+			if _, ok := t.locations[next]; !ok {
+				break
+			}
+			rolled[next] += v
+		}
+	}
+	return rolled
+}
+
+// DevicesUnder lists the devices at a location or anywhere under it in the registry's order.
+// A leaf location is one query, otherwise the company's devices are listed once and filtered by location.
+func DevicesUnder(ctx context.Context, registry DeviceRegistry, tree *LocationTree, locationUID string) ([]*Device, error) {
+	root, err := tree.Get(locationUID)
+	if err != nil {
+		return nil, err
+	}
+	locations, err := tree.Descendants(locationUID)
+	if err != nil {
+		return nil, err
+	}
+	query := DeviceQuery{CompanyUID: root.CompanyUID}
+	if len(locations) == 1 {
+		query.LocationUID = locationUID
+	}
+	found, err := registry.ListDevices(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not list devices under %s: %w", locationUID, err)
+	}
+	under := make(map[string]bool, len(locations))
+	for _, uid := range locations {
+		under[uid] = true
+	}
+	var devices []*Device
+	for _, d := range found {
+		if d.DeviceMeta != nil && under[d.LocationUID] {
+			devices = append(devices, d)
+		}
+	}
+	return devices, nil
+}
+
+// DeviceLocationPath gives the locations from the root down to the device's location
+func DeviceLocationPath(ctx context.Context, registry DeviceRegistry, tree *LocationTree, deviceUID string) ([]Location, error) {
+	d, err := registry.GetDevice(ctx, deviceUID)
+	if err != nil {
+		return nil, err
+	}
+	if d.DeviceMeta == nil || d.LocationUID == "" {
+		return nil, fmt.Errorf("%w: device %s has no location", ErrLocationNotFound, deviceUID)
+	}
+	return tree.Path(d.LocationUID)
+}
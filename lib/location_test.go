@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func testLocations() []Location {
+	return []Location{
+		{UID: "estate", CompanyUID: "c1", Kind: Estate},
+		{UID: "b1", CompanyUID: "c1", ParentUID: "estate", Kind: Building},
+		{UID: "b1-f1", CompanyUID: "c1", ParentUID: "b1", Kind: Floor},
+		{UID: "4b", CompanyUID: "c1", ParentUID: "b1-f1", Kind: Room},
+		{UID: "4c", CompanyUID: "c1", ParentUID: "b1-f1", Kind: Room},
+		{UID: "b2", CompanyUID: "c1", ParentUID: "estate", Kind: Building},
+	}
+}
+
+func TestNewLocationTree(t *testing.T) {
+	tests := []struct {
+		name      string
+		locations []Location
+		wantErr   error
+	}{
+		{name: "tree", locations: testLocations()},
+		{
+			name:      "missing parent",
+			locations: []Location{{UID: "room", CompanyUID: "c1", ParentUID: "floor"}},
+			wantErr:   ErrLocationNotFound,
+		},
+		{
+			name: "cycle",
+			locations: []Location{
+				{UID: "a", CompanyUID: "c1", ParentUID: "b"},
+				{UID: "b", CompanyUID: "c1", ParentUID: "a"},
+			},
+			wantErr: ErrLocationCycle,
+		},
+		{
+			name:      "self parent",
+			locations: []Location{{UID: "a", CompanyUID: "c1", ParentUID: "a"}},
+			wantErr:   ErrLocationCycle,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLocationTree(tt.locations)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewLocationTree() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := NewLocationTree([]Location{{UID: "a", CompanyUID: "c1"}, {UID: "b", CompanyUID: "c2", ParentUID: "a"}}); err == nil {
+		t.Errorf("NewLocationTree() should refuse a parent in another company")
+	}
+}
+
+func TestLocationTree(t *testing.T) {
+	tree, err := NewLocationTree(testLocations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := tree.Path("4b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uids []string
+	for _, l := range path {
+		uids = append(uids, l.UID)
+	}
+	if fmt.Sprint(uids) != "[estate b1 b1-f1 4b]" {
+		t.Errorf("Path() = %v", uids)
+	}
+
+	descendants, _ := tree.Descendants("b1")
+	if fmt.Sprint(descendants) != "[b1 b1-f1 4b 4c]" {
+		t.Errorf("Descendants() = %v", descendants)
+	}
+
+	rolled := tree.RollUp(map[string]float64{"4b": 2, "4c": 3, "b2": 5, "elsewhere": 7})
+	if rolled["b1-f1"] != 5 || rolled["b1"] != 5 || rolled["estate"] != 10 || rolled["b2"] != 5 {
+		t.Errorf("RollUp() = %v", rolled)
+	}
+
+	if err = tree.Put(Location{UID: "b1", CompanyUID: "c1", ParentUID: "4b"}); !errors.Is(err, ErrLocationCycle) {
+		t.Errorf("Put() under a descendant error = %v, want ErrLocationCycle", err)
+	}
+	if l, _ := tree.Get("b1"); l.ParentUID != "estate" {
+		t.Errorf("failed Put() changed the location to %+v", l)
+	}
+	if err = tree.Put(Location{UID: "b1-f1", CompanyUID: "c1", ParentUID: "b2"}); err != nil {
+		t.Fatalf("Put() move error = %v", err)
+	}
+	if fmt.Sprint(tree.Children("b1")) != "[]" || fmt.Sprint(tree.Children("b2")) != "[b1-f1]" {
+		t.Errorf("Children() after move = %v, %v", tree.Children("b1"), tree.Children("b2"))
+	}
+}
+
+// countingRegistry counts ListDevices queries
+type countingRegistry struct {
+	*MemoryDeviceRegistry
+	lists int
+}
+
+func (r *countingRegistry) ListDevices(ctx context.Context, query DeviceQuery) ([]*Device, error) {
+	r.lists++
+	return r.MemoryDeviceRegistry.ListDevices(ctx, query)
+}
+
+func TestDevicesUnder(t *testing.T) {
+	ctx := context.Background()
+	tree, _ := NewLocationTree(testLocations())
+	registry := &countingRegistry{MemoryDeviceRegistry: NewMemoryDeviceRegistry()}
+	for uid, location := range map[string]string{"cooler": "4b", "lights": "4c", "meter": "b1", "pump": "b2"} {
+		_ = registry.UpsertDevice(ctx, &Device{DeviceUID: uid, DeviceMeta: &DeviceMeta{CompanyUID: "c1", LocationUID: location}})
+	}
+
+	devices, err := DevicesUnder(ctx, registry, tree, "b1")
+	if err != nil || len(devices) != 3 || registry.lists != 1 {
+		t.Errorf("DevicesUnder() = %d devices from %d queries, %v", len(devices), registry.lists, err)
+	}
+	if devices, err = DevicesUnder(ctx, registry, tree, "4c"); err != nil || len(devices) != 1 || devices[0].DeviceUID != "lights" {
+		t.Errorf("DevicesUnder() leaf = %v, %v", devices, err)
+	}
+	path, err := DeviceLocationPath(ctx, registry, tree, "cooler")
+	if err != nil || len(path) != 4 || path[0].Kind != Estate {
+		t.Errorf("DeviceLocationPath() = %v, %v", path, err)
+	}
+	if _, err = DevicesUnder(ctx, registry, tree, "b9"); !errors.Is(err, ErrLocationNotFound) {
+		t.Errorf("DevicesUnder() error = %v, want ErrLocationNotFound", err)
+	}
+}
@@ -0,0 +1,510 @@
+package ota
+
+import (
+	"errors"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotInCampaign is returned for devices the campaign did not select
+	ErrNotInCampaign = errors.New("device not in campaign")
+	// ErrCampaignState is returned when an operation doesn't fit the campaign or device state
+	ErrCampaignState = errors.New("invalid campaign state")
+)
+
+type CampaignState string
+
+const (
+	CampaignDraft     CampaignState = "draft"
+	CampaignRunning   CampaignState = "running"
+	CampaignHalted    CampaignState = "halted"
+	CampaignCompleted CampaignState = "completed"
+)
+
+type DeviceState string
+
+const (
+	DevicePending   DeviceState = "pending"
+	DeviceSent      DeviceState = "sent"
+	DeviceConfirmed DeviceState = "confirmed"
+	DeviceFailed    DeviceState = "failed"
+)
+
+// Selector chooses the devices a campaign updates, empty fields match all devices.
+// DeviceGroup isn't held on DeviceMeta so a Category with a DeviceGroup needs Group to resolve a device's group.
+type Selector struct {
+	Category     lib.Category `json:"category"`
+	CompanyUID   string       `json:"companyUID,omitempty"`
+	LocationUID  string       `json:"locationUID,omitempty"`
+	FirmwareName string       `json:"firmwareName,omitempty"`
+	// Versions is a lib.Constraint on the current firmware e.g. ">=1.2 <2.0"
+	Versions string                              `json:"versions,omitempty"`
+	Group    func(d *lib.Device) lib.DeviceGroup `json:"-"`
+}
+
+func (s Selector) Matches(d *lib.Device) (bool, error) {
+	if d.DeviceMeta == nil {
+		return false, nil
+	}
+	query := lib.DeviceQuery{CompanyUID: s.CompanyUID, LocationUID: s.LocationUID, DeviceType: s.Category.DeviceType}
+	if !query.Matches(d) {
+		return false, nil
+	}
+	if s.Category.DeviceGroup != "" {
+		if s.Group == nil {
+			return false, fmt.Errorf("selector on group %s needs a Group func", s.Category.DeviceGroup)
+		}
+		if s.Group(d) != s.Category.DeviceGroup {
+			return false, nil
+		}
+	}
+	if s.FirmwareName != "" && (d.Firmware == nil || d.Firmware.FirmwareName != s.FirmwareName) {
+		return false, nil
+	}
+	if s.Versions != "" {
+		constraint, err := lib.ParseConstraint(s.Versions)
+		if err != nil {
+			return false, err
+		}
+		if d.Firmware == nil {
+			return false, nil
+		}
+		// devices reporting unparseable versions are left out rather than failing the campaign
+		v, err := d.Firmware.ParsedVersion()
+		if err != nil || !constraint.Matches(v) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Wave is a stage of the rollout, Percent is the cumulative share of the campaign's devices updated by the end of it
+type Wave struct {
+	Percent float64 `json:"percent"`
+}
+
+// DeviceRollout is a device's progress through the campaign
+type DeviceRollout struct {
+	DeviceUID string      `json:"uid"`
+	Wave      int         `json:"wave"`
+	State     DeviceState `json:"state"`
+	// FromVersion is the firmware version the device had when selected
+	FromVersion string    `json:"fromVersion,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type CampaignConfig struct {
+	ID     string       `json:"id"`
+	Target lib.Firmware `json:"target"`
+	Waves  []Wave       `json:"waves"`
+	// FailureThreshold halts the campaign when failed / finished devices exceeds it, 0 halts on the first failure
+	FailureThreshold float64 `json:"failureThreshold"`
+	// MinFinished is how many devices must have confirmed or failed before the threshold applies
+	MinFinished int `json:"minFinished"`
+}
+
+func (c CampaignConfig) Validate() error {
+	var errs []error
+	if c.ID == "" {
+		errs = append(errs, fmt.Errorf("campaign needs an id"))
+	}
+	if err := c.Target.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("target: %w", err))
+	}
+	if len(c.Waves) == 0 {
+		errs = append(errs, fmt.Errorf("campaign needs at least one wave"))
+	}
+	last := 0.0
+	for i, w := range c.Waves {
+		if w.Percent <= last || w.Percent > 100 {
+			errs = append(errs, fmt.Errorf("wave %d percent %v must increase and be at most 100", i, w.Percent))
+		}
+		last = w.Percent
+	}
+	if len(c.Waves) > 0 && last != 100 {
+		errs = append(errs, fmt.Errorf("final wave must reach 100 percent"))
+	}
+	if c.FailureThreshold < 0 || c.FailureThreshold > 1 {
+		errs = append(errs, fmt.Errorf("failure threshold %v must be between 0 and 1", c.FailureThreshold))
+	}
+	return errors.Join(errs...)
+}
+
+// Campaign rolls Target firmware out to the selected devices wave by wave.
+// Devices in the current and earlier waves are Due for sending, AdvanceWave moves on once every device in the
+// current wave has confirmed or failed. The campaign halts itself when failures pass the threshold.
+// Snapshot and RestoreCampaign carry a campaign across restarts through a CampaignStore.
+type Campaign struct {
+	mu          sync.Mutex
+	config      CampaignConfig
+	target      lib.Version
+	state       CampaignState
+	currentWave int
+	devices     map[string]*DeviceRollout
+	haltReason  string
+}
+
+// NewCampaign selects devices for the campaign, devices already on or above the target version are left out.
+// Devices are spread over waves by a hash of their DeviceUID so the same devices go first if a campaign is rebuilt.
+func NewCampaign(config CampaignConfig, selector Selector, devices []*lib.Device) (*Campaign, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	target, _ := config.Target.ParsedVersion()
+
+	var selected []*lib.Device
+	for _, d := range devices {
+		ok, err := selector.Matches(d)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if d.Firmware != nil && d.Firmware.FirmwareName == config.Target.FirmwareName {
+			if v, err := d.Firmware.ParsedVersion(); err == nil && !v.Less(target) {
+				continue
+			}
+		}
+		selected = append(selected, d)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		hi, hj := hashUID(selected[i].DeviceUID), hashUID(selected[j].DeviceUID)
+		if hi != hj {
+			return hi < hj
+		}
+		return selected[i].DeviceUID < selected[j].DeviceUID
+	})
+
+	c := &Campaign{
+		config:  config,
+		target:  target,
+		state:   CampaignDraft,
+		devices: make(map[string]*DeviceRollout, len(selected)),
+	}
+	wave := 0
+	for i, d := range selected {
+		for wave < len(config.Waves)-1 && i >= waveCutoff(config.Waves[wave], len(selected)) {
+			wave++
+		}
+		rollout := &DeviceRollout{DeviceUID: d.DeviceUID, Wave: wave, State: DevicePending}
+		if d.Firmware != nil {
+			rollout.FromVersion = d.Firmware.FirmwareVersion
+		}
+		c.devices[d.DeviceUID] = rollout
+	}
+	return c, nil
+}
+
+// CampaignSnapshot is the serialisable state of a Campaign, a CampaignStore saves it so a rollout survives restarts
+type CampaignSnapshot struct {
+	Config      CampaignConfig  `json:"config"`
+	State       CampaignState   `json:"state"`
+	CurrentWave int             `json:"currentWave"`
+	HaltReason  string          `json:"haltReason,omitempty"`
+	Devices     []DeviceRollout `json:"devices"`
+}
+
+// RestoreCampaign rebuilds a campaign from a snapshot, devices are not reselected so it carries on where it left off
+func RestoreCampaign(s CampaignSnapshot) (*Campaign, error) {
+	if err := s.Config.Validate(); err != nil {
+		return nil, err
+	}
+	switch s.State {
+	case CampaignDraft, CampaignRunning, CampaignHalted, CampaignCompleted:
+	default:
+		return nil, fmt.Errorf("%w: unknown state %s", ErrCampaignState, s.State)
+	}
+	if s.CurrentWave < 0 || s.CurrentWave >= len(s.Config.Waves) {
+		return nil, fmt.Errorf("%w: wave %d of %d", ErrCampaignState, s.CurrentWave, len(s.Config.Waves))
+	}
+	target, _ := s.Config.Target.ParsedVersion()
+
+	c := &Campaign{
+		config:      s.Config,
+		target:      target,
+		state:       s.State,
+		currentWave: s.CurrentWave,
+		haltReason:  s.HaltReason,
+		devices:     make(map[string]*DeviceRollout, len(s.Devices)),
+	}
+	c.config.Waves = append([]Wave(nil), s.Config.Waves...)
+	for _, d := range s.Devices {
+		if _, ok := c.devices[d.DeviceUID]; ok || d.DeviceUID == "" {
+			return nil, fmt.Errorf("%w: device %q is missing or repeated", ErrCampaignState, d.DeviceUID)
+		}
+		if d.Wave < 0 || d.Wave >= len(s.Config.Waves) {
+			return nil, fmt.Errorf("%w: device %s in wave %d of %d", ErrCampaignState, d.DeviceUID, d.Wave, len(s.Config.Waves))
+		}
+		switch d.State {
+		case DevicePending, DeviceSent, DeviceConfirmed, DeviceFailed:
+		default:
+			return nil, fmt.Errorf("%w: device %s has unknown state %s", ErrCampaignState, d.DeviceUID, d.State)
+		}
+		rollout := d
+		c.devices[d.DeviceUID] = &rollout
+	}
+	return c, nil
+}
+
+func hashUID(uid string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(uid))
+	return h.Sum32()
+}
+
+// waveCutoff is the number of devices updated by the end of a wave, at least one device goes in a non empty wave
+func waveCutoff(w Wave, n int) int {
+	return int(math.Max(1, math.Ceil(float64(n)*w.Percent/100)))
+}
+
+func (c *Campaign) ID() string {
+	return c.config.ID
+}
+
+func (c *Campaign) State() CampaignState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// HaltReason says why a halted campaign stopped
+func (c *Campaign) HaltReason() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.haltReason
+}
+
+func (c *Campaign) CurrentWave() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.currentWave
+}
+
+func (c *Campaign) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != CampaignDraft && c.state != CampaignHalted {
+		return fmt.Errorf("%w: cannot start a %s campaign", ErrCampaignState, c.state)
+	}
+	c.state = CampaignRunning
+	c.haltReason = ""
+	c.completeIfDone()
+	return nil
+}
+
+// Halt stops the campaign, Due returns nothing until it is started again
+func (c *Campaign) Halt(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.halt(reason)
+}
+
+func (c *Campaign) halt(reason string) {
+	if c.state == CampaignRunning {
+		c.state = CampaignHalted
+		c.haltReason = reason
+	}
+}
+
+// Due lists the pending devices in waves up to the current one ordered by DeviceUID, nothing is due unless running
+func (c *Campaign) Due() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != CampaignRunning {
+		return nil
+	}
+	var due []string
+	for uid, d := range c.devices {
+		if d.State == DevicePending && d.Wave <= c.currentWave {
+			due = append(due, uid)
+		}
+	}
+	sort.Strings(due)
+	return due
+}
+
+func (c *Campaign) Device(uid string) (DeviceRollout, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.devices[uid]
+	if !ok {
+		return DeviceRollout{}, fmt.Errorf("%w: %s", ErrNotInCampaign, uid)
+	}
+	return *d, nil
+}
+
+// Snapshot copies the campaign's state for a CampaignStore
+func (c *Campaign) Snapshot() CampaignSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := CampaignSnapshot{
+		Config:      c.config,
+		State:       c.state,
+		CurrentWave: c.currentWave,
+		HaltReason:  c.haltReason,
+		Devices:     c.rollouts(),
+	}
+	s.Config.Waves = append([]Wave(nil), c.config.Waves...)
+	return s
+}
+
+// Devices lists every device's rollout ordered by wave then DeviceUID
+func (c *Campaign) Devices() []DeviceRollout {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rollouts()
+}
+
+func (c *Campaign) rollouts() []DeviceRollout {
+	devices := make([]DeviceRollout, 0, len(c.devices))
+	for _, d := range c.devices {
+		devices = append(devices, *d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Wave != devices[j].Wave {
+			return devices[i].Wave < devices[j].Wave
+		}
+		return devices[i].DeviceUID < devices[j].DeviceUID
+	})
+	return devices
+}
+
+// Counts gives the number of devices in each state
+func (c *Campaign) Counts() map[DeviceState]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts()
+}
+
+func (c *Campaign) counts() map[DeviceState]int {
+	counts := make(map[DeviceState]int)
+	for _, d := range c.devices {
+		counts[d.State]++
+	}
+	return counts
+}
+
+func (c *Campaign) MarkSent(uid string, at time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, err := c.device(uid)
+	if err != nil {
+		return err
+	}
+	if d.State != DevicePending {
+		return fmt.Errorf("%w: %s is %s not pending", ErrCampaignState, uid, d.State)
+	}
+	d.State, d.UpdatedAt = DeviceSent, at
+	return nil
+}
+
+// MarkFailed records a failed update and halts the campaign if failures pass the threshold
+func (c *Campaign) MarkFailed(uid string, reason string, at time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, err := c.device(uid)
+	if err != nil {
+		return err
+	}
+	if d.State == DeviceConfirmed {
+		return fmt.Errorf("%w: %s is already confirmed", ErrCampaignState, uid)
+	}
+	d.State, d.Error, d.UpdatedAt = DeviceFailed, reason, at
+	c.checkFailures()
+	return nil
+}
+
+// Reconcile compares the firmware a device reports with the target, typically from metadata on an incoming message.
+// A device reporting the target version or above is confirmed, even if it was updated outside the campaign.
+// It reports whether the device's state changed.
+func (c *Campaign) Reconcile(d lib.Device, at time.Time) (bool, error) {
+	if d.DeviceMeta == nil || d.Firmware == nil || d.Firmware.FirmwareName != c.config.Target.FirmwareName {
+		return false, nil
+	}
+	v, err := d.Firmware.ParsedVersion()
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rollout, err := c.device(d.DeviceUID)
+	if err != nil {
+		return false, err
+	}
+	if rollout.State == DeviceConfirmed || v.Less(c.target) {
+		return false, nil
+	}
+	rollout.State, rollout.Error, rollout.UpdatedAt = DeviceConfirmed, "", at
+	c.completeIfDone()
+	return true, nil
+}
+
+// AdvanceWave moves to the next wave once every device in the current wave has confirmed or failed, reporting
+// whether it moved
+func (c *Campaign) AdvanceWave() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != CampaignRunning {
+		return false, fmt.Errorf("%w: cannot advance a %s campaign", ErrCampaignState, c.state)
+	}
+	if c.currentWave >= len(c.config.Waves)-1 || !c.waveDone(c.currentWave) {
+		return false, nil
+	}
+	c.currentWave++
+	c.completeIfDone()
+	return true, nil
+}
+
+func (c *Campaign) device(uid string) (*DeviceRollout, error) {
+	d, ok := c.devices[uid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotInCampaign, uid)
+	}
+	return d, nil
+}
+
+func (c *Campaign) waveDone(wave int) bool {
+	for _, d := range c.devices {
+		if d.Wave <= wave && (d.State == DevicePending || d.State == DeviceSent) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Campaign) checkFailures() {
+	counts := c.counts()
+	finished := counts[DeviceConfirmed] + counts[DeviceFailed]
+	if finished == 0 || finished < c.config.MinFinished {
+		return
+	}
+	rate := float64(counts[DeviceFailed]) / float64(finished)
+	if rate > c.config.FailureThreshold {
+		c.halt(fmt.Sprintf("failure rate %.2f above threshold %.2f", rate, c.config.FailureThreshold))
+		return
+	}
+	c.completeIfDone()
+}
+
+func (c *Campaign) completeIfDone() {
+	if c.state == CampaignRunning && c.currentWave == len(c.config.Waves)-1 && c.waveDone(c.currentWave) {
+		c.state = CampaignCompleted
+	}
+}
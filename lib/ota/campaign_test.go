@@ -0,0 +1,169 @@
+package ota
+
+import (
+	"errors"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"testing"
+	"time"
+)
+
+func testDevices(n int, version string) []*lib.Device {
+	var devices []*lib.Device
+	for i := 0; i < n; i++ {
+		devices = append(devices, &lib.Device{
+			DeviceUID: fmt.Sprintf("dev-%02d", i),
+			DeviceMeta: &lib.DeviceMeta{
+				DeviceType: lib.Power,
+				CompanyUID: "c1",
+				Firmware:   &lib.Firmware{FirmwareName: "pm", FirmwareVersion: version},
+			},
+		})
+	}
+	return devices
+}
+
+func testConfig() CampaignConfig {
+	return CampaignConfig{
+		ID:               "pm-2.0",
+		Target:           lib.Firmware{FirmwareName: "pm", FirmwareVersion: "2.0.0"},
+		Waves:            []Wave{{Percent: 10}, {Percent: 50}, {Percent: 100}},
+		FailureThreshold: 0.5,
+		MinFinished:      2,
+	}
+}
+
+func TestNewCampaign(t *testing.T) {
+	devices := testDevices(20, "1.4")
+	devices = append(devices,
+		&lib.Device{DeviceUID: "current", DeviceMeta: &lib.DeviceMeta{DeviceType: lib.Power, CompanyUID: "c1",
+			Firmware: &lib.Firmware{FirmwareName: "pm", FirmwareVersion: "2.0"}}},
+		&lib.Device{DeviceUID: "lighting", DeviceMeta: &lib.DeviceMeta{DeviceType: lib.Lighting, CompanyUID: "c1"}},
+		&lib.Device{DeviceUID: "old", DeviceMeta: &lib.DeviceMeta{DeviceType: lib.Power, CompanyUID: "c1",
+			Firmware: &lib.Firmware{FirmwareName: "pm", FirmwareVersion: "0.9"}}},
+	)
+	selector := Selector{Category: lib.Category{DeviceType: lib.Power}, CompanyUID: "c1", Versions: ">=1.0 <2.0"}
+
+	c, err := NewCampaign(testConfig(), selector, devices)
+	if err != nil {
+		t.Fatalf("NewCampaign() error = %v", err)
+	}
+	perWave := make(map[int]int)
+	for _, d := range c.Devices() {
+		perWave[d.Wave]++
+	}
+	if perWave[0] != 2 || perWave[1] != 8 || perWave[2] != 10 {
+		t.Errorf("devices per wave = %v, want 2, 8, 10", perWave)
+	}
+	for _, uid := range []string{"current", "lighting", "old"} {
+		if _, err = c.Device(uid); !errors.Is(err, ErrNotInCampaign) {
+			t.Errorf("Device(%s) error = %v, want ErrNotInCampaign", uid, err)
+		}
+	}
+
+	again, _ := NewCampaign(testConfig(), selector, devices)
+	for _, d := range c.Devices() {
+		if r, _ := again.Device(d.DeviceUID); r.Wave != d.Wave {
+			t.Errorf("device %s moved from wave %d to %d when rebuilt", d.DeviceUID, d.Wave, r.Wave)
+		}
+	}
+}
+
+func TestCampaignConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *CampaignConfig)
+	}{
+		{name: "no id", modify: func(c *CampaignConfig) { c.ID = "" }},
+		{name: "bad target", modify: func(c *CampaignConfig) { c.Target.FirmwareVersion = "next" }},
+		{name: "decreasing waves", modify: func(c *CampaignConfig) { c.Waves = []Wave{{Percent: 50}, {Percent: 10}, {Percent: 100}} }},
+		{name: "short of 100", modify: func(c *CampaignConfig) { c.Waves = []Wave{{Percent: 50}} }},
+		{name: "threshold", modify: func(c *CampaignConfig) { c.FailureThreshold = 2 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			tt.modify(&config)
+			if err := config.Validate(); err == nil {
+				t.Errorf("Validate() should fail")
+			}
+		})
+	}
+}
+
+func TestCampaign_Rollout(t *testing.T) {
+	now := time.Now()
+	c, err := NewCampaign(testConfig(), Selector{}, testDevices(10, "1.4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if due := c.Due(); len(due) != 0 {
+		t.Errorf("Due() before start = %v", due)
+	}
+	if err = c.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for c.State() == CampaignRunning {
+		due := c.Due()
+		for _, uid := range due {
+			if err = c.MarkSent(uid, now); err != nil {
+				t.Fatal(err)
+			}
+			reported := lib.Device{DeviceUID: uid, DeviceMeta: &lib.DeviceMeta{
+				Firmware: &lib.Firmware{FirmwareName: "pm", FirmwareVersion: "2.0.0"},
+			}}
+			if changed, err := c.Reconcile(reported, now); err != nil || !changed {
+				t.Fatalf("Reconcile() = %v, %v", changed, err)
+			}
+		}
+		if moved, err := c.AdvanceWave(); err != nil && c.State() == CampaignRunning {
+			t.Fatal(err)
+		} else if !moved && c.State() == CampaignRunning {
+			t.Fatalf("AdvanceWave() did not move from wave %d", c.CurrentWave())
+		}
+	}
+	if c.State() != CampaignCompleted || c.Counts()[DeviceConfirmed] != 10 {
+		t.Errorf("campaign %s with counts %v", c.State(), c.Counts())
+	}
+}
+
+func TestCampaign_Halt(t *testing.T) {
+	now := time.Now()
+	config := testConfig()
+	config.Waves = []Wave{{Percent: 100}}
+	c, err := NewCampaign(config, Selector{}, testDevices(6, "1.4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Start()
+	due := c.Due()
+
+	if err = c.MarkFailed(due[0], "timeout", now); err != nil {
+		t.Fatal(err)
+	}
+	if c.State() != CampaignRunning {
+		t.Fatalf("halted before MinFinished")
+	}
+	reported := lib.Device{DeviceUID: due[1], DeviceMeta: &lib.DeviceMeta{
+		Firmware: &lib.Firmware{FirmwareName: "pm", FirmwareVersion: "2.1"},
+	}}
+	if _, err = c.Reconcile(reported, now); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.MarkFailed(due[2], "bricked", now); err != nil {
+		t.Fatal(err)
+	}
+	if c.State() != CampaignHalted || c.HaltReason() == "" {
+		t.Fatalf("campaign %s after 2 of 3 failed, want halted", c.State())
+	}
+	if len(c.Due()) != 0 {
+		t.Errorf("Due() on a halted campaign = %v", c.Due())
+	}
+	if _, err = c.AdvanceWave(); !errors.Is(err, ErrCampaignState) {
+		t.Errorf("AdvanceWave() error = %v, want ErrCampaignState", err)
+	}
+	if err = c.MarkSent(due[1], now); !errors.Is(err, ErrCampaignState) {
+		t.Errorf("MarkSent() on a confirmed device error = %v, want ErrCampaignState", err)
+	}
+}
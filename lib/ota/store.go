@@ -0,0 +1,101 @@
+package ota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrCampaignNotFound is returned by a CampaignStore when no campaign has the id
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+// CampaignStore saves campaign snapshots by Config.ID so a rollout can be restored with RestoreCampaign
+type CampaignStore interface {
+	GetCampaign(ctx context.Context, id string) (*CampaignSnapshot, error)
+	ListCampaigns(ctx context.Context) ([]*CampaignSnapshot, error)
+	// SaveCampaign creates or replaces the campaign with s's Config.ID
+	SaveCampaign(ctx context.Context, s *CampaignSnapshot) error
+	DeleteCampaign(ctx context.Context, id string) error
+}
+
+// MemoryCampaignStore is a CampaignStore held in memory for tests and small tools
+type MemoryCampaignStore struct {
+	mu        sync.RWMutex
+	campaigns map[string]*CampaignSnapshot
+}
+
+func NewMemoryCampaignStore() *MemoryCampaignStore {
+	return &MemoryCampaignStore{campaigns: make(map[string]*CampaignSnapshot)}
+}
+
+func (m *MemoryCampaignStore) GetCampaign(ctx context.Context, id string) (*CampaignSnapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.campaigns[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCampaignNotFound, id)
+	}
+	return copySnapshot(s), nil
+}
+
+// ListCampaigns returns every campaign ordered by id
+func (m *MemoryCampaignStore) ListCampaigns(ctx context.Context) ([]*CampaignSnapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	campaigns := make([]*CampaignSnapshot, 0, len(m.campaigns))
+	for _, s := range m.campaigns {
+		campaigns = append(campaigns, copySnapshot(s))
+	}
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].Config.ID < campaigns[j].Config.ID
+	})
+	return campaigns, nil
+}
+
+func (m *MemoryCampaignStore) SaveCampaign(ctx context.Context, s *CampaignSnapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s == nil || s.Config.ID == "" {
+		return fmt.Errorf("campaign needs an id")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.campaigns[s.Config.ID] = copySnapshot(s)
+	return nil
+}
+
+func (m *MemoryCampaignStore) DeleteCampaign(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.campaigns[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrCampaignNotFound, id)
+	}
+	delete(m.campaigns, id)
+	return nil
+}
+
+// copySnapshot stops callers sharing the store's records
+func copySnapshot(s *CampaignSnapshot) *CampaignSnapshot {
+	c := *s
+	c.Config.Waves = append([]Wave(nil), s.Config.Waves...)
+	c.Devices = append([]DeviceRollout(nil), s.Devices...)
+	return &c
+}
+
+var _ CampaignStore = (*MemoryCampaignStore)(nil)
@@ -0,0 +1,97 @@
+package ota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryCampaignStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryCampaignStore()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	config := testConfig()
+	config.Waves = []Wave{{Percent: 50}, {Percent: 100}}
+	c, err := NewCampaign(config, Selector{}, testDevices(10, "1.4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Start()
+	due := c.Due()
+	if err = c.MarkSent(due[0], now); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := c.Snapshot()
+	if err = store.SaveCampaign(ctx, &snapshot); err != nil {
+		t.Fatalf("SaveCampaign() error = %v", err)
+	}
+	snapshot.Devices[0].State = DeviceFailed
+
+	saved, err := store.GetCampaign(ctx, "pm-2.0")
+	if err != nil {
+		t.Fatalf("GetCampaign() error = %v", err)
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded CampaignSnapshot
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreCampaign(decoded)
+	if err != nil {
+		t.Fatalf("RestoreCampaign() error = %v", err)
+	}
+	if !reflect.DeepEqual(restored.Devices(), c.Devices()) {
+		t.Errorf("restored devices = %v, want %v", restored.Devices(), c.Devices())
+	}
+	if restored.State() != CampaignRunning || restored.CurrentWave() != 0 {
+		t.Errorf("restored campaign %s in wave %d", restored.State(), restored.CurrentWave())
+	}
+	if got := restored.Due(); !reflect.DeepEqual(got, due[1:]) {
+		t.Errorf("restored Due() = %v, want %v", got, due[1:])
+	}
+
+	campaigns, err := store.ListCampaigns(ctx)
+	if err != nil || len(campaigns) != 1 {
+		t.Errorf("ListCampaigns() = %v, %v", campaigns, err)
+	}
+	if err = store.DeleteCampaign(ctx, "pm-2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.GetCampaign(ctx, "pm-2.0"); !errors.Is(err, ErrCampaignNotFound) {
+		t.Errorf("GetCampaign() after delete error = %v, want ErrCampaignNotFound", err)
+	}
+}
+
+func TestRestoreCampaign_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *CampaignSnapshot)
+	}{
+		{name: "config", modify: func(s *CampaignSnapshot) { s.Config.ID = "" }},
+		{name: "state", modify: func(s *CampaignSnapshot) { s.State = "paused" }},
+		{name: "wave", modify: func(s *CampaignSnapshot) { s.CurrentWave = 3 }},
+		{name: "device wave", modify: func(s *CampaignSnapshot) { s.Devices[0].Wave = -1 }},
+		{name: "device state", modify: func(s *CampaignSnapshot) { s.Devices[0].State = "lost" }},
+		{name: "repeated device", modify: func(s *CampaignSnapshot) { s.Devices[1].DeviceUID = s.Devices[0].DeviceUID }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCampaign(testConfig(), Selector{}, testDevices(4, "1.4"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := c.Snapshot()
+			tt.modify(&s)
+			if _, err = RestoreCampaign(s); err == nil {
+				t.Errorf("RestoreCampaign() should fail")
+			}
+		})
+	}
+}
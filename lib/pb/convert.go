@@ -0,0 +1,159 @@
+// Package pb holds the protobuf form of the lib device model and stream.SimpleMessage.
+// Each .proto file has a single top level message so it can be used with gbigquery.CreateProtoSchema.
+//
+// Conversions round trip every field, times keep their instant to the nanosecond but come back in UTC as
+// google.protobuf.Timestamp has no zone, and Processors values come back as the types encoding/json decodes to.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative device.proto simple_message.proto device_skeleton.proto
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"github.com/safecility/go/lib/stream"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+// toTimestamp leaves the zero time unset so it isn't confused with the unix epoch
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func DeviceToProto(d *lib.Device) (*Device, error) {
+	if d == nil {
+		return nil, nil
+	}
+	p := &Device{Uid: d.DeviceUID}
+	if d.DeviceMeta == nil {
+		return p, nil
+	}
+	meta := &Device_Meta{
+		DeviceName:  d.DeviceName,
+		DeviceTag:   d.DeviceTag,
+		DeviceType:  string(d.DeviceType),
+		CompanyUid:  d.CompanyUID,
+		LocationUid: d.LocationUID,
+		Firmware:    FirmwareToProto(d.Firmware),
+		Status:      string(d.Status),
+	}
+	if d.Processors != nil {
+		b, err := json.Marshal(d.Processors)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode processors of %s: %w", d.DeviceUID, err)
+		}
+		meta.Processors = string(b)
+	}
+	for _, t := range d.StatusHistory {
+		meta.StatusHistory = append(meta.StatusHistory, &Device_LifecycleTransition{
+			From:   string(t.From),
+			To:     string(t.To),
+			At:     toTimestamp(t.At),
+			Reason: t.Reason,
+		})
+	}
+	p.Meta = meta
+	return p, nil
+}
+
+func DeviceFromProto(p *Device) (*lib.Device, error) {
+	if p == nil {
+		return nil, nil
+	}
+	d := &lib.Device{DeviceUID: p.GetUid()}
+	meta := p.GetMeta()
+	if meta == nil {
+		return d, nil
+	}
+	d.DeviceMeta = &lib.DeviceMeta{
+		DeviceName:  meta.GetDeviceName(),
+		DeviceTag:   meta.GetDeviceTag(),
+		DeviceType:  lib.DeviceType(meta.GetDeviceType()),
+		CompanyUID:  meta.GetCompanyUid(),
+		LocationUID: meta.GetLocationUid(),
+		Firmware:    FirmwareFromProto(meta.GetFirmware()),
+		Status:      lib.LifecycleState(meta.GetStatus()),
+	}
+	if meta.GetProcessors() != "" {
+		processors := lib.Processor{}
+		if err := json.Unmarshal([]byte(meta.GetProcessors()), &processors); err != nil {
+			return nil, fmt.Errorf("could not decode processors of %s: %w", p.GetUid(), err)
+		}
+		d.Processors = &processors
+	}
+	for _, t := range meta.GetStatusHistory() {
+		d.StatusHistory = append(d.StatusHistory, lib.LifecycleTransition{
+			From:   lib.LifecycleState(t.GetFrom()),
+			To:     lib.LifecycleState(t.GetTo()),
+			At:     fromTimestamp(t.GetAt()),
+			Reason: t.GetReason(),
+		})
+	}
+	return d, nil
+}
+
+func FirmwareToProto(f *lib.Firmware) *Device_Firmware {
+	if f == nil {
+		return nil
+	}
+	return &Device_Firmware{Name: f.FirmwareName, Version: f.FirmwareVersion}
+}
+
+func FirmwareFromProto(p *Device_Firmware) *lib.Firmware {
+	if p == nil {
+		return nil
+	}
+	return &lib.Firmware{FirmwareName: p.GetName(), FirmwareVersion: p.GetVersion()}
+}
+
+func CategoryToProto(c lib.Category) *DeviceSkeleton_Category {
+	return &DeviceSkeleton_Category{DeviceType: string(c.DeviceType), DeviceGroup: string(c.DeviceGroup)}
+}
+
+func CategoryFromProto(p *DeviceSkeleton_Category) lib.Category {
+	return lib.Category{DeviceType: lib.DeviceType(p.GetDeviceType()), DeviceGroup: lib.DeviceGroup(p.GetDeviceGroup())}
+}
+
+func DeviceSkeletonToProto(s lib.DeviceSkeleton) *DeviceSkeleton {
+	return &DeviceSkeleton{
+		DeviceUid:      s.DeviceUID,
+		ServiceUid:     s.ServiceUID,
+		DeviceCategory: CategoryToProto(s.DeviceCategory),
+	}
+}
+
+func DeviceSkeletonFromProto(p *DeviceSkeleton) lib.DeviceSkeleton {
+	return lib.DeviceSkeleton{
+		DeviceUID:      p.GetDeviceUid(),
+		ServiceUID:     p.GetServiceUid(),
+		DeviceCategory: CategoryFromProto(p.GetDeviceCategory()),
+	}
+}
+
+func SimpleMessageToProto(m stream.SimpleMessage) *SimpleMessage {
+	return &SimpleMessage{
+		Source:    m.Source,
+		DeviceUid: m.DeviceUID,
+		Payload:   m.Payload,
+		Time:      toTimestamp(m.Time),
+	}
+}
+
+func SimpleMessageFromProto(p *SimpleMessage) stream.SimpleMessage {
+	return stream.SimpleMessage{
+		BrokerDevice: stream.BrokerDevice{Source: p.GetSource(), DeviceUID: p.GetDeviceUid()},
+		Payload:      p.GetPayload(),
+		Time:         fromTimestamp(p.GetTime()),
+	}
+}
@@ -0,0 +1,114 @@
+package pb
+
+import (
+	"github.com/safecility/go/lib"
+	"github.com/safecility/go/lib/stream"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDevice_RoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	tests := []struct {
+		name string
+		d    *lib.Device
+	}{
+		{name: "uid only", d: &lib.Device{DeviceUID: "a"}},
+		{name: "empty meta", d: &lib.Device{DeviceUID: "a", DeviceMeta: &lib.DeviceMeta{}}},
+		{
+			name: "full",
+			d: &lib.Device{DeviceUID: "a", DeviceMeta: &lib.DeviceMeta{
+				DeviceName:  "cooler",
+				DeviceTag:   "cooler-4b",
+				DeviceType:  lib.Power,
+				CompanyUID:  "c1",
+				LocationUID: "4b",
+				Firmware:    &lib.Firmware{FirmwareName: "ka", FirmwareVersion: "1.2.3"},
+				Processors:  &lib.Processor{"scale": map[string]interface{}{"factor": 2.5}, "enabled": true},
+				Status:      lib.Active,
+				StatusHistory: []lib.LifecycleTransition{
+					{From: lib.Commissioned, To: lib.Active, At: at, Reason: "installed"},
+					{From: lib.Active, To: lib.Maintenance, At: time.Unix(0, 0).UTC()},
+				},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := DeviceToProto(tt.d)
+			if err != nil {
+				t.Fatalf("DeviceToProto() error = %v", err)
+			}
+			b, err := proto.Marshal(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := &Device{}
+			if err = proto.Unmarshal(b, decoded); err != nil {
+				t.Fatal(err)
+			}
+			got, err := DeviceFromProto(decoded)
+			if err != nil {
+				t.Fatalf("DeviceFromProto() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.d) {
+				t.Errorf("round trip = %+v, want %+v", got.DeviceMeta, tt.d.DeviceMeta)
+			}
+		})
+	}
+}
+
+func TestDeviceSkeleton_RoundTrip(t *testing.T) {
+	s := lib.DeviceSkeleton{
+		DeviceUID:      "a",
+		ServiceUID:     "tts",
+		DeviceCategory: lib.Category{DeviceType: lib.Power, DeviceGroup: lib.Meter},
+	}
+	if got := DeviceSkeletonFromProto(DeviceSkeletonToProto(s)); got != s {
+		t.Errorf("round trip = %+v, want %+v", got, s)
+	}
+}
+
+func TestSimpleMessage_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		m    stream.SimpleMessage
+	}{
+		{
+			name: "message",
+			m: stream.SimpleMessage{
+				BrokerDevice: stream.BrokerDevice{Source: "tts", DeviceUID: "a"},
+				Payload:      []byte{1, 2, 3},
+				Time:         time.Date(2024, 3, 1, 12, 0, 0, 500001, time.UTC),
+			},
+		},
+		{
+			name: "not utc",
+			m: stream.SimpleMessage{
+				BrokerDevice: stream.BrokerDevice{DeviceUID: "a"},
+				Time:         time.Date(2024, 3, 1, 12, 0, 0, 7, time.FixedZone("IST", 5*3600+1800)),
+			},
+		},
+		{name: "epoch", m: stream.SimpleMessage{BrokerDevice: stream.BrokerDevice{DeviceUID: "a"}, Time: time.Unix(0, 0)}},
+		{name: "zero time", m: stream.SimpleMessage{BrokerDevice: stream.BrokerDevice{DeviceUID: "a"}, Payload: []byte{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := proto.Marshal(SimpleMessageToProto(tt.m))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := &SimpleMessage{}
+			if err = proto.Unmarshal(b, decoded); err != nil {
+				t.Fatal(err)
+			}
+			got := SimpleMessageFromProto(decoded)
+			if got.BrokerDevice != tt.m.BrokerDevice || string(got.Payload) != string(tt.m.Payload) ||
+				!got.Time.Equal(tt.m.Time) || got.Time.IsZero() != tt.m.Time.IsZero() {
+				t.Errorf("round trip = %+v, want %+v", got, tt.m)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.1
+// 	protoc        (unknown)
+// source: device_skeleton.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// DeviceSkeleton is lib.DeviceSkeleton with its lib.Category
+type DeviceSkeleton struct {
+	state          protoimpl.MessageState   `protogen:"open.v1"`
+	DeviceUid      string                   `protobuf:"bytes,1,opt,name=device_uid,json=deviceUid,proto3" json:"device_uid,omitempty"`
+	ServiceUid     string                   `protobuf:"bytes,2,opt,name=service_uid,json=serviceUid,proto3" json:"service_uid,omitempty"`
+	DeviceCategory *DeviceSkeleton_Category `protobuf:"bytes,3,opt,name=device_category,json=deviceCategory,proto3" json:"device_category,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *DeviceSkeleton) Reset() {
+	*x = DeviceSkeleton{}
+	mi := &file_device_skeleton_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceSkeleton) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceSkeleton) ProtoMessage() {}
+
+func (x *DeviceSkeleton) ProtoReflect() protoreflect.Message {
+	mi := &file_device_skeleton_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceSkeleton.ProtoReflect.Descriptor instead.
+func (*DeviceSkeleton) Descriptor() ([]byte, []int) {
+	return file_device_skeleton_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DeviceSkeleton) GetDeviceUid() string {
+	if x != nil {
+		return x.DeviceUid
+	}
+	return ""
+}
+
+func (x *DeviceSkeleton) GetServiceUid() string {
+	if x != nil {
+		return x.ServiceUid
+	}
+	return ""
+}
+
+func (x *DeviceSkeleton) GetDeviceCategory() *DeviceSkeleton_Category {
+	if x != nil {
+		return x.DeviceCategory
+	}
+	return nil
+}
+
+type DeviceSkeleton_Category struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceType    string                 `protobuf:"bytes,1,opt,name=device_type,json=deviceType,proto3" json:"device_type,omitempty"`
+	DeviceGroup   string                 `protobuf:"bytes,2,opt,name=device_group,json=deviceGroup,proto3" json:"device_group,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeviceSkeleton_Category) Reset() {
+	*x = DeviceSkeleton_Category{}
+	mi := &file_device_skeleton_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceSkeleton_Category) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceSkeleton_Category) ProtoMessage() {}
+
+func (x *DeviceSkeleton_Category) ProtoReflect() protoreflect.Message {
+	mi := &file_device_skeleton_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceSkeleton_Category.ProtoReflect.Descriptor instead.
+func (*DeviceSkeleton_Category) Descriptor() ([]byte, []int) {
+	return file_device_skeleton_proto_rawDescGZIP(), []int{0, 0}
+}
+
+func (x *DeviceSkeleton_Category) GetDeviceType() string {
+	if x != nil {
+		return x.DeviceType
+	}
+	return ""
+}
+
+func (x *DeviceSkeleton_Category) GetDeviceGroup() string {
+	if x != nil {
+		return x.DeviceGroup
+	}
+	return ""
+}
+
+var File_device_skeleton_proto protoreflect.FileDescriptor
+
+var file_device_skeleton_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x5f, 0x73, 0x6b, 0x65, 0x6c, 0x65, 0x74, 0x6f,
+	0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0e, 0x73, 0x61, 0x66, 0x65, 0x63, 0x69, 0x6c,
+	0x69, 0x74, 0x79, 0x2e, 0x6c, 0x69, 0x62, 0x22, 0xf2, 0x01, 0x0a, 0x0e, 0x44, 0x65, 0x76, 0x69,
+	0x63, 0x65, 0x53, 0x6b, 0x65, 0x6c, 0x65, 0x74, 0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x64, 0x65,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x75, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x55, 0x69, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x75, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a,
+	0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x55, 0x69, 0x64, 0x12, 0x50, 0x0a, 0x0f, 0x64, 0x65,
+	0x76, 0x69, 0x63, 0x65, 0x5f, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x73, 0x61, 0x66, 0x65, 0x63, 0x69, 0x6c, 0x69, 0x74, 0x79,
+	0x2e, 0x6c, 0x69, 0x62, 0x2e, 0x44, 0x65, 0x76, 0x69, 0x63, 0x65, 0x53, 0x6b, 0x65, 0x6c, 0x65,
+	0x74, 0x6f, 0x6e, 0x2e, 0x43, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x52, 0x0e, 0x64, 0x65,
+	0x76, 0x69, 0x63, 0x65, 0x43, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x1a, 0x4e, 0x0a, 0x08,
+	0x43, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x12, 0x1f, 0x0a, 0x0b, 0x64, 0x65, 0x76, 0x69,
+	0x63, 0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x64,
+	0x65, 0x76, 0x69, 0x63, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x65, 0x76,
+	0x69, 0x63, 0x65, 0x5f, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0b, 0x64, 0x65, 0x76, 0x69, 0x63, 0x65, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x42, 0x21, 0x5a, 0x1f,
+	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x73, 0x61, 0x66, 0x65, 0x63,
+	0x69, 0x6c, 0x69, 0x74, 0x79, 0x2f, 0x67, 0x6f, 0x2f, 0x6c, 0x69, 0x62, 0x2f, 0x70, 0x62, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_device_skeleton_proto_rawDescOnce sync.Once
+	file_device_skeleton_proto_rawDescData = file_device_skeleton_proto_rawDesc
+)
+
+func file_device_skeleton_proto_rawDescGZIP() []byte {
+	file_device_skeleton_proto_rawDescOnce.Do(func() {
+		file_device_skeleton_proto_rawDescData = protoimpl.X.CompressGZIP(file_device_skeleton_proto_rawDescData)
+	})
+	return file_device_skeleton_proto_rawDescData
+}
+
+var file_device_skeleton_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_device_skeleton_proto_goTypes = []any{
+	(*DeviceSkeleton)(nil),          // 0: safecility.lib.DeviceSkeleton
+	(*DeviceSkeleton_Category)(nil), // 1: safecility.lib.DeviceSkeleton.Category
+}
+var file_device_skeleton_proto_depIdxs = []int32{
+	1, // 0: safecility.lib.DeviceSkeleton.device_category:type_name -> safecility.lib.DeviceSkeleton.Category
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_device_skeleton_proto_init() }
+func file_device_skeleton_proto_init() {
+	if File_device_skeleton_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_device_skeleton_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_device_skeleton_proto_goTypes,
+		DependencyIndexes: file_device_skeleton_proto_depIdxs,
+		MessageInfos:      file_device_skeleton_proto_msgTypes,
+	}.Build()
+	File_device_skeleton_proto = out.File
+	file_device_skeleton_proto_rawDesc = nil
+	file_device_skeleton_proto_goTypes = nil
+	file_device_skeleton_proto_depIdxs = nil
+}
@@ -0,0 +1,206 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrDeviceNotFound is returned by a DeviceRegistry when no device matches
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrDeviceConflict is returned by a DeviceRegistry when a write would give a DeviceTag to two devices in a company
+	// or a tag lookup finds more than one device
+	ErrDeviceConflict = errors.New("device conflict")
+)
+
+// DeviceQuery selects devices for ListDevices, empty fields match all devices
+type DeviceQuery struct {
+	CompanyUID  string     `json:"companyUID,omitempty"`
+	LocationUID string     `json:"locationUID,omitempty"`
+	DeviceType  DeviceType `json:"type,omitempty"`
+}
+
+// Matches reports whether d is selected by the query, devices without meta only match an empty query
+func (q DeviceQuery) Matches(d *Device) bool {
+	if q == (DeviceQuery{}) {
+		return true
+	}
+	if d.DeviceMeta == nil {
+		return false
+	}
+	return (q.CompanyUID == "" || q.CompanyUID == d.CompanyUID) &&
+		(q.LocationUID == "" || q.LocationUID == d.LocationUID) &&
+		(q.DeviceType == "" || q.DeviceType == d.DeviceType)
+}
+
+// DeviceRegistry stores Device records, errors wrap ErrDeviceNotFound or ErrDeviceConflict so callers can use errors.Is
+type DeviceRegistry interface {
+	GetDevice(ctx context.Context, uid string) (*Device, error)
+	// GetDeviceByTag finds the device currently serving a DeviceTag, tags are unique within a company
+	GetDeviceByTag(ctx context.Context, companyUID, tag string) (*Device, error)
+	ListDevices(ctx context.Context, query DeviceQuery) ([]*Device, error)
+	// UpsertDevice creates or replaces the device with d's DeviceUID
+	UpsertDevice(ctx context.Context, d *Device) error
+	// UpdateDevice reads the device, applies update and writes it back atomically so concurrent updates aren't lost.
+	// An error from update abandons the write and is returned as is, update may be called more than once.
+	UpdateDevice(ctx context.Context, uid string, update func(d *Device) error) (*Device, error)
+	DeleteDevice(ctx context.Context, uid string) error
+}
+
+// MemoryDeviceRegistry is a DeviceRegistry held in memory for tests and small tools
+type MemoryDeviceRegistry struct {
+	mu      sync.RWMutex
+	devices map[string]*Device
+}
+
+func NewMemoryDeviceRegistry() *MemoryDeviceRegistry {
+	return &MemoryDeviceRegistry{devices: make(map[string]*Device)}
+}
+
+func (r *MemoryDeviceRegistry) GetDevice(ctx context.Context, uid string) (*Device, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, ok := r.devices[uid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, uid)
+	}
+	return copyDevice(d), nil
+}
+
+func (r *MemoryDeviceRegistry) GetDeviceByTag(ctx context.Context, companyUID, tag string) (*Device, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var found *Device
+	for _, d := range r.devices {
+		if d.DeviceMeta == nil || d.CompanyUID != companyUID || d.DeviceTag != tag {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: tag %s held by %s and %s", ErrDeviceConflict, tag, found.DeviceUID, d.DeviceUID)
+		}
+		found = d
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: tag %s in company %s", ErrDeviceNotFound, tag, companyUID)
+	}
+	return copyDevice(found), nil
+}
+
+// ListDevices returns matching devices ordered by DeviceUID
+func (r *MemoryDeviceRegistry) ListDevices(ctx context.Context, query DeviceQuery) ([]*Device, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var devices []*Device
+	for _, d := range r.devices {
+		if query.Matches(d) {
+			devices = append(devices, copyDevice(d))
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].DeviceUID < devices[j].DeviceUID
+	})
+	return devices, nil
+}
+
+func (r *MemoryDeviceRegistry) UpsertDevice(ctx context.Context, d *Device) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d == nil || d.DeviceUID == "" {
+		return fmt.Errorf("device needs a uid")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.put(d)
+}
+
+func (r *MemoryDeviceRegistry) UpdateDevice(ctx context.Context, uid string, update func(d *Device) error) (*Device, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.devices[uid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, uid)
+	}
+	d := copyDevice(existing)
+	if err := update(d); err != nil {
+		return nil, err
+	}
+	if d.DeviceUID != uid {
+		return nil, fmt.Errorf("update can't change device %s's uid", uid)
+	}
+	if err := r.put(d); err != nil {
+		return nil, err
+	}
+	return copyDevice(d), nil
+}
+
+// put stores a copy of d unless its DeviceTag is held by another device in the company, r.mu must be held
+func (r *MemoryDeviceRegistry) put(d *Device) error {
+	if d.DeviceMeta != nil && d.DeviceTag != "" {
+		for _, existing := range r.devices {
+			if existing.DeviceUID != d.DeviceUID && existing.DeviceMeta != nil &&
+				existing.CompanyUID == d.CompanyUID && existing.DeviceTag == d.DeviceTag {
+				return fmt.Errorf("%w: tag %s is held by %s", ErrDeviceConflict, d.DeviceTag, existing.DeviceUID)
+			}
+		}
+	}
+	r.devices[d.DeviceUID] = copyDevice(d)
+	return nil
+}
+
+func (r *MemoryDeviceRegistry) DeleteDevice(ctx context.Context, uid string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.devices[uid]; !ok {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, uid)
+	}
+	delete(r.devices, uid)
+	return nil
+}
+
+// copyDevice stops callers sharing the registry's records, Processors is shallow copied
+func copyDevice(d *Device) *Device {
+	c := &Device{DeviceUID: d.DeviceUID}
+	if d.DeviceMeta != nil {
+		meta := *d.DeviceMeta
+		if meta.Firmware != nil {
+			firmware := *meta.Firmware
+			meta.Firmware = &firmware
+		}
+		if meta.Processors != nil {
+			processors := make(Processor, len(*meta.Processors))
+			for k, v := range *meta.Processors {
+				processors[k] = v
+			}
+			meta.Processors = &processors
+		}
+		if meta.StatusHistory != nil {
+			meta.StatusHistory = append([]LifecycleTransition(nil), meta.StatusHistory...)
+		}
+		c.DeviceMeta = &meta
+	}
+	return c
+}
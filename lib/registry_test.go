@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryDeviceRegistry(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryDeviceRegistry()
+	var _ DeviceRegistry = r
+
+	devices := []*Device{
+		{DeviceUID: "a", DeviceMeta: &DeviceMeta{DeviceTag: "cooler-4b", DeviceType: Power, CompanyUID: "c1", LocationUID: "l1"}},
+		{DeviceUID: "b", DeviceMeta: &DeviceMeta{DeviceTag: "lights-4b", DeviceType: Lighting, CompanyUID: "c1", LocationUID: "l1"}},
+		{DeviceUID: "c", DeviceMeta: &DeviceMeta{DeviceTag: "cooler-4b", DeviceType: Power, CompanyUID: "c2", LocationUID: "l2"}},
+		{DeviceUID: "d"},
+	}
+	for _, d := range devices {
+		if err := r.UpsertDevice(ctx, d); err != nil {
+			t.Fatalf("UpsertDevice(%s) error = %v", d.DeviceUID, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		query   DeviceQuery
+		wantUID []string
+	}{
+		{name: "all", query: DeviceQuery{}, wantUID: []string{"a", "b", "c", "d"}},
+		{name: "company", query: DeviceQuery{CompanyUID: "c1"}, wantUID: []string{"a", "b"}},
+		{name: "type", query: DeviceQuery{DeviceType: Power}, wantUID: []string{"a", "c"}},
+		{name: "location and type", query: DeviceQuery{LocationUID: "l1", DeviceType: Lighting}, wantUID: []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.ListDevices(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("ListDevices() error = %v", err)
+			}
+			var uids []string
+			for _, d := range got {
+				uids = append(uids, d.DeviceUID)
+			}
+			if len(uids) != len(tt.wantUID) {
+				t.Fatalf("ListDevices() = %v, want %v", uids, tt.wantUID)
+			}
+			for i := range uids {
+				if uids[i] != tt.wantUID[i] {
+					t.Errorf("ListDevices() = %v, want %v", uids, tt.wantUID)
+				}
+			}
+		})
+	}
+
+	d, err := r.GetDeviceByTag(ctx, "c2", "cooler-4b")
+	if err != nil || d.DeviceUID != "c" {
+		t.Errorf("GetDeviceByTag() = %v, %v", d, err)
+	}
+
+	clash := &Device{DeviceUID: "e", DeviceMeta: &DeviceMeta{DeviceTag: "cooler-4b", CompanyUID: "c1"}}
+	if err = r.UpsertDevice(ctx, clash); !errors.Is(err, ErrDeviceConflict) {
+		t.Errorf("UpsertDevice() with a held tag error = %v, want ErrDeviceConflict", err)
+	}
+
+	d, _ = r.GetDevice(ctx, "a")
+	d.DeviceName = "changed"
+	if stored, _ := r.GetDevice(ctx, "a"); stored.DeviceName != "" {
+		t.Errorf("GetDevice() returned the stored record rather than a copy")
+	}
+
+	if err = r.DeleteDevice(ctx, "a"); err != nil {
+		t.Fatalf("DeleteDevice() error = %v", err)
+	}
+	if _, err = r.GetDevice(ctx, "a"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("GetDevice() after delete error = %v, want ErrDeviceNotFound", err)
+	}
+	if err = r.DeleteDevice(ctx, "a"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("DeleteDevice() twice error = %v, want ErrDeviceNotFound", err)
+	}
+}
@@ -0,0 +1,222 @@
+package stream
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"sync"
+	"time"
+)
+
+// BatchSettings exposes the pubsub batching knobs for an AsyncPublisher, zero values keep the pubsub defaults
+type BatchSettings struct {
+	// CountThreshold publishes a batch once it holds this many messages
+	CountThreshold int `json:"countThreshold"`
+	// ByteThreshold publishes a batch once it holds this many bytes
+	ByteThreshold int `json:"byteThreshold"`
+	// DelayThreshold publishes a batch once its oldest message has waited this long
+	DelayThreshold time.Duration `json:"delayThreshold"`
+	// BufferedByteLimit caps the bytes held waiting to be sent, publishes fail once it is reached
+	BufferedByteLimit int `json:"bufferedByteLimit"`
+	// EnableMessageOrdering must be set to publish with an OrderingKey
+	EnableMessageOrdering bool `json:"enableMessageOrdering"`
+}
+
+func (bs BatchSettings) apply(topic *pubsub.Topic) {
+	if bs.CountThreshold > 0 {
+		topic.PublishSettings.CountThreshold = bs.CountThreshold
+	}
+	if bs.ByteThreshold > 0 {
+		topic.PublishSettings.ByteThreshold = bs.ByteThreshold
+	}
+	if bs.DelayThreshold > 0 {
+		topic.PublishSettings.DelayThreshold = bs.DelayThreshold
+	}
+	if bs.BufferedByteLimit > 0 {
+		topic.PublishSettings.BufferedByteLimit = bs.BufferedByteLimit
+	}
+	if bs.EnableMessageOrdering {
+		topic.EnableMessageOrdering = true
+	}
+}
+
+// ResultCallback is called once per message published by an AsyncPublisher with the message as passed to Publish
+// and either the server's message id or the error - a failed message can be retried or persisted from here
+type ResultCallback func(m interface{}, id string, err error)
+
+// PublishFuture is the pending result of an asynchronous publish
+type PublishFuture struct {
+	ready chan struct{}
+	id    string
+	err   error
+}
+
+func newPublishFuture() *PublishFuture {
+	return &PublishFuture{ready: make(chan struct{})}
+}
+
+func failedPublishFuture(err error) *PublishFuture {
+	f := newPublishFuture()
+	f.resolve("", err)
+	return f
+}
+
+func (f *PublishFuture) resolve(id string, err error) {
+	f.id = id
+	f.err = err
+	close(f.ready)
+}
+
+// Ready is closed once the result is available
+func (f *PublishFuture) Ready() <-chan struct{} {
+	return f.ready
+}
+
+// Get waits for the server's message id, returning early with ctx's error if ctx is done first
+func (f *PublishFuture) Get(ctx context.Context) (string, error) {
+	select {
+	case <-f.ready:
+		return f.id, f.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// AsyncPublisher enqueues messages on a pubsub topic without waiting for each ack so the client can batch them.
+// Call Flush or Stop before shutdown so queued readings are not lost.
+type AsyncPublisher struct {
+	topic    *pubsub.Topic
+	callback ResultCallback
+
+	mu      sync.RWMutex
+	stopped bool
+
+	// pending counts results not yet resolved, a cond rather than a WaitGroup as publishes may start while Flush waits
+	pendingMu   sync.Mutex
+	pendingDone *sync.Cond
+	pending     int
+}
+
+// NewAsyncPublisher applies settings to topic - callback may be nil if the caller only uses the returned futures
+func NewAsyncPublisher(topic *pubsub.Topic, settings BatchSettings, callback ResultCallback) *AsyncPublisher {
+	if topic != nil {
+		settings.apply(topic)
+	}
+	p := &AsyncPublisher{topic: topic, callback: callback}
+	p.pendingDone = sync.NewCond(&p.pendingMu)
+	return p
+}
+
+// Publish marshals m to json and enqueues it
+func (p *AsyncPublisher) Publish(ctx context.Context, m interface{}, opts ...PublishOption) *PublishFuture {
+	data, err := marshalJSON(m)
+	if err != nil {
+		return p.fail(m, err)
+	}
+	return p.enqueue(ctx, m, newMessage(data, time.Now(), opts))
+}
+
+// PublishProto marshals m with the topic schema's encoding and enqueues it
+func (p *AsyncPublisher) PublishProto(ctx context.Context, m proto.Message, encoding pubsub.SchemaEncoding, opts ...PublishOption) *PublishFuture {
+	data, err := marshalProto(m, encoding)
+	if err != nil {
+		return p.fail(m, err)
+	}
+	return p.enqueue(ctx, m, newMessage(data, time.Time{}, opts))
+}
+
+// ResumePublish lets an ordering key publish again after a failure.
+// Unlike the synchronous publishes, the AsyncPublisher does not resume keys itself - messages queued behind the failed
+// one have also failed, so the caller should republish them in order from the ResultCallback before resuming.
+func (p *AsyncPublisher) ResumePublish(orderingKey string) {
+	if p.topic != nil {
+		p.topic.ResumePublish(orderingKey)
+	}
+}
+
+func (p *AsyncPublisher) fail(m interface{}, err error) *PublishFuture {
+	if p.callback != nil {
+		p.callback(m, "", err)
+	}
+	return failedPublishFuture(err)
+}
+
+func (p *AsyncPublisher) enqueue(ctx context.Context, m interface{}, message *pubsub.Message) *PublishFuture {
+	if p.topic == nil {
+		return p.fail(m, fmt.Errorf("no topic configured"))
+	}
+	// the read lock keeps Stop from closing the topic between the stopped check and the topic publish
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return p.fail(m, fmt.Errorf("publisher is stopped"))
+	}
+
+	result := p.topic.Publish(ctx, message)
+	future := newPublishFuture()
+	p.addPending(1)
+	go func() {
+		defer p.addPending(-1)
+		// the result always resolves once the topic sends or fails the batch
+		id, err := result.Get(context.Background())
+		if err != nil {
+			err = fmt.Errorf("could not publish to topic %v: %w", p.topic, err)
+		}
+		future.resolve(id, err)
+		if p.callback != nil {
+			p.callback(m, id, err)
+		}
+	}()
+	return future
+}
+
+// Flush sends all queued messages and waits until every result and callback has completed.
+// Publishes wait for the topic flush as pubsub's Topic.Flush isn't safe alongside the topic's first Publish or Stop.
+func (p *AsyncPublisher) Flush() {
+	if p.topic == nil {
+		return
+	}
+	p.mu.Lock()
+	if !p.stopped {
+		p.topic.Flush()
+	}
+	p.mu.Unlock()
+	p.waitPending()
+}
+
+// Stop refuses new messages, drains the queue and stops the topic's publishing goroutines
+func (p *AsyncPublisher) Stop() {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	p.stopped = true
+	p.mu.Unlock()
+
+	if p.topic == nil {
+		return
+	}
+	p.topic.Stop()
+	p.waitPending()
+}
+
+func (p *AsyncPublisher) addPending(delta int) {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+
+	p.pending += delta
+	if p.pending == 0 {
+		p.pendingDone.Broadcast()
+	}
+}
+
+func (p *AsyncPublisher) waitPending() {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+
+	for p.pending > 0 {
+		p.pendingDone.Wait()
+	}
+}
@@ -0,0 +1,102 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncPublisher_Publish(t *testing.T) {
+	client, srv := newTestClient(t)
+	topic, err := client.CreateTopic(context.Background(), "async")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+
+	var mu sync.Mutex
+	results := map[int]string{}
+	p := NewAsyncPublisher(topic, BatchSettings{CountThreshold: 5, DelayThreshold: time.Second}, func(m interface{}, id string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			results[m.(int)] = err.Error()
+			return
+		}
+		results[m.(int)] = id
+	})
+
+	const count = 12
+	futures := make([]*PublishFuture, count)
+	for i := 0; i < count; i++ {
+		futures[i] = p.Publish(context.Background(), i)
+	}
+	p.Flush()
+
+	if len(results) != count {
+		t.Fatalf("Flush() returned with %d of %d callbacks", len(results), count)
+	}
+	for i, f := range futures {
+		id, err := f.Get(context.Background())
+		if err != nil || id != results[i] {
+			t.Errorf("future %d = %s, %v want %s", i, id, err, results[i])
+		}
+	}
+	if got := len(srv.Messages()); got != count {
+		t.Errorf("server received %d messages, want %d", got, count)
+	}
+
+	p.Stop()
+	if _, err = p.Publish(context.Background(), count).Get(context.Background()); err == nil {
+		t.Errorf("Publish() after Stop() should fail")
+	}
+	if results[count] != err.Error() {
+		t.Errorf("callback after Stop() got %s, want %v", results[count], err)
+	}
+}
+
+func TestPublishFuture_Get(t *testing.T) {
+	f := newPublishFuture()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.Get(ctx); err == nil {
+		t.Errorf("Get() on an unresolved future should return the context error")
+	}
+
+	f.resolve("id", nil)
+	if id, err := f.Get(context.Background()); id != "id" || err != nil {
+		t.Errorf("Get() = %s, %v want id, nil", id, err)
+	}
+}
+
+func TestAsyncPublisher_FlushWhilePublishing(t *testing.T) {
+	client, _ := newTestClient(t)
+	topic, err := client.CreateTopic(context.Background(), "async-flush")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+	p := NewAsyncPublisher(topic, BatchSettings{}, nil)
+	defer p.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				p.Publish(context.Background(), j)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			p.Flush()
+		}()
+	}
+	wg.Wait()
+	p.Flush()
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+	if p.pending != 0 {
+		t.Errorf("Flush() returned with %d results pending", p.pending)
+	}
+}
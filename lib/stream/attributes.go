@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"cloud.google.com/go/pubsub"
+	"github.com/safecility/go/lib"
+	"time"
+)
+
+// Attribute keys set on published messages so subscriptions can filter without decoding payloads
+const (
+	AttributeSource        = "source"
+	AttributeDeviceUID     = "deviceUID"
+	AttributeDeviceTag     = "deviceTag"
+	AttributeDeviceType    = "deviceType"
+	AttributeCompanyUID    = "companyUID"
+	AttributeLocationUID   = "locationUID"
+	AttributeSchemaVersion = "schemaVersion"
+)
+
+// PublishOptions are the per message settings built up from PublishOption
+type PublishOptions struct {
+	Attributes map[string]string
+	// OrderingKey keeps messages with the same key in order - the topic must have EnableMessageOrdering set
+	OrderingKey string
+}
+
+type PublishOption func(*PublishOptions)
+
+func WithAttribute(key, value string) PublishOption {
+	return func(o *PublishOptions) {
+		if o.Attributes == nil {
+			o.Attributes = make(map[string]string)
+		}
+		o.Attributes[key] = value
+	}
+}
+
+// WithAttributes merges attributes into any already set, later options win
+func WithAttributes(attributes map[string]string) PublishOption {
+	return func(o *PublishOptions) {
+		for k, v := range attributes {
+			WithAttribute(k, v)(o)
+		}
+	}
+}
+
+func WithOrderingKey(key string) PublishOption {
+	return func(o *PublishOptions) {
+		o.OrderingKey = key
+	}
+}
+
+func WithSchemaVersion(version string) PublishOption {
+	return WithAttribute(AttributeSchemaVersion, version)
+}
+
+// WithBrokerDevice sets the source and device attributes, add WithOrderingKey(bd.DeviceUID) to order per device on
+// a topic with EnableMessageOrdering
+func WithBrokerDevice(bd BrokerDevice) PublishOption {
+	return WithAttributes(bd.Attributes())
+}
+
+// WithDevice sets the attributes known from the device's meta
+func WithDevice(d lib.Device) PublishOption {
+	return WithAttributes(DeviceAttributes(d))
+}
+
+// Attributes of a BrokerDevice, empty fields are left out
+func (bd BrokerDevice) Attributes() map[string]string {
+	attributes := make(map[string]string)
+	setAttribute(attributes, AttributeSource, bd.Source)
+	setAttribute(attributes, AttributeDeviceUID, bd.DeviceUID)
+	return attributes
+}
+
+// DeviceAttributes gives the attributes of a Device and whatever DeviceMeta it carries, empty fields are left out
+func DeviceAttributes(d lib.Device) map[string]string {
+	attributes := make(map[string]string)
+	setAttribute(attributes, AttributeDeviceUID, d.DeviceUID)
+	if d.DeviceMeta == nil {
+		return attributes
+	}
+	setAttribute(attributes, AttributeDeviceTag, d.DeviceTag)
+	setAttribute(attributes, AttributeDeviceType, string(d.DeviceType))
+	setAttribute(attributes, AttributeCompanyUID, d.CompanyUID)
+	setAttribute(attributes, AttributeLocationUID, d.LocationUID)
+	return attributes
+}
+
+func setAttribute(attributes map[string]string, key, value string) {
+	if value != "" {
+		attributes[key] = value
+	}
+}
+
+func newPublishOptions(opts []PublishOption) PublishOptions {
+	var o PublishOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func newMessage(data []byte, publishTime time.Time, opts []PublishOption) *pubsub.Message {
+	o := newPublishOptions(opts)
+	return &pubsub.Message{
+		Data:        data,
+		PublishTime: publishTime,
+		Attributes:  o.Attributes,
+		OrderingKey: o.OrderingKey,
+	}
+}
@@ -0,0 +1,106 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/safecility/go/lib"
+	"reflect"
+	"testing"
+)
+
+func TestDeviceAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		device lib.Device
+		want   map[string]string
+	}{
+		{
+			name:   "uid only",
+			device: lib.Device{DeviceUID: "device-1"},
+			want:   map[string]string{AttributeDeviceUID: "device-1"},
+		},
+		{
+			name: "with meta",
+			device: lib.Device{
+				DeviceUID: "device-1",
+				DeviceMeta: &lib.DeviceMeta{
+					DeviceTag:   "cooler-4b",
+					DeviceType:  lib.Power,
+					CompanyUID:  "company",
+					LocationUID: "location",
+				},
+			},
+			want: map[string]string{
+				AttributeDeviceUID:   "device-1",
+				AttributeDeviceTag:   "cooler-4b",
+				AttributeDeviceType:  "power",
+				AttributeCompanyUID:  "company",
+				AttributeLocationUID: "location",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeviceAttributes(tt.device); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeviceAttributes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryPublisher_PublishOptions(t *testing.T) {
+	p := NewMemoryPublisher()
+	bd := BrokerDevice{Source: "ttn", DeviceUID: "device-1"}
+	_, err := p.Publish(context.Background(), SimpleMessage{BrokerDevice: bd},
+		WithBrokerDevice(bd), WithSchemaVersion("2"), WithAttribute(AttributeSource, "chirpstack"))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	got := p.Messages()[0]
+	want := map[string]string{
+		AttributeSource:        "chirpstack",
+		AttributeDeviceUID:     "device-1",
+		AttributeSchemaVersion: "2",
+	}
+	if !reflect.DeepEqual(got.Attributes, want) {
+		t.Errorf("Publish() attributes = %v, want %v", got.Attributes, want)
+	}
+	if got.OrderingKey != "" {
+		t.Errorf("Publish() ordering key = %s, device attributes shouldn't order", got.OrderingKey)
+	}
+}
+
+func TestMemoryPublisher_OrderingKey(t *testing.T) {
+	p := NewMemoryPublisher()
+	if _, err := p.Publish(context.Background(), SimpleMessage{}, WithOrderingKey("device-1")); !errors.Is(err, ErrOrderingNotEnabled) {
+		t.Fatalf("Publish() error = %v, want ErrOrderingNotEnabled", err)
+	}
+	p.EnableMessageOrdering = true
+	if _, err := p.Publish(context.Background(), SimpleMessage{}, WithOrderingKey("device-1")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if got := p.Messages()[0].OrderingKey; got != "device-1" {
+		t.Errorf("Publish() ordering key = %s, want device-1", got)
+	}
+}
+
+func TestPublishToTopicContext_Ordered(t *testing.T) {
+	client, srv := newTestClient(t)
+	topic, err := client.CreateTopic(context.Background(), "ordered")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+	defer topic.Stop()
+	topic.EnableMessageOrdering = true
+
+	id, err := PublishToTopicContext(context.Background(), SimpleMessage{}, topic,
+		WithOrderingKey("device-1"), WithAttribute(AttributeSource, "test"))
+	if err != nil {
+		t.Fatalf("PublishToTopicContext() error = %v", err)
+	}
+	m := srv.Message(*id)
+	if m == nil || m.OrderingKey != "device-1" || m.Attributes[AttributeSource] != "test" {
+		t.Errorf("PublishToTopicContext() server message = %+v", m)
+	}
+}
@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// chirpStackUplinkEvent is the part of a ChirpStack v4 json uplink event we use
+type chirpStackUplinkEvent struct {
+	Time       time.Time `json:"time"`
+	DeviceInfo struct {
+		DeviceName string `json:"deviceName"`
+		DevEUI     string `json:"devEui"`
+	} `json:"deviceInfo"`
+	DevAddr string `json:"devAddr"`
+	FCnt    uint32 `json:"fCnt"`
+	FPort   uint32 `json:"fPort"`
+	Data    []byte `json:"data"`
+	RxInfo  []struct {
+		GatewayID string    `json:"gatewayId"`
+		RSSI      float64   `json:"rssi"`
+		SNR       float64   `json:"snr"`
+		NsTime    time.Time `json:"nsTime"`
+	} `json:"rxInfo"`
+	TxInfo struct {
+		Frequency  uint64 `json:"frequency"`
+		Modulation struct {
+			LoRa struct {
+				Bandwidth       uint32 `json:"bandwidth"`
+				SpreadingFactor uint32 `json:"spreadingFactor"`
+			} `json:"lora"`
+		} `json:"modulation"`
+	} `json:"txInfo"`
+}
+
+// DecodeChirpStackUplink decodes a ChirpStack v4 uplink event in its json marshaler format
+func DecodeChirpStackUplink(data []byte) (*LoRaWANUplink, error) {
+	var event chirpStackUplinkEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("could not unmarshall chirpstack event: %v", err)
+	}
+	if event.DeviceInfo.DevEUI == "" {
+		return nil, fmt.Errorf("chirpstack event for %s has no devEui", event.DeviceInfo.DeviceName)
+	}
+
+	radio := RadioMetadata{
+		DevEUI:          event.DeviceInfo.DevEUI,
+		DevAddr:         event.DevAddr,
+		FPort:           event.FPort,
+		FCnt:            event.FCnt,
+		Frequency:       event.TxInfo.Frequency,
+		SpreadingFactor: event.TxInfo.Modulation.LoRa.SpreadingFactor,
+		Bandwidth:       event.TxInfo.Modulation.LoRa.Bandwidth,
+	}
+	received := event.Time
+	for _, rx := range event.RxInfo {
+		radio.Gateways = append(radio.Gateways, GatewayMetadata{
+			GatewayID: rx.GatewayID,
+			RSSI:      rx.RSSI,
+			SNR:       rx.SNR,
+		})
+		// time is only set on the event when a gateway supplies one, fall back to the network server time
+		if received.IsZero() {
+			received = rx.NsTime
+		}
+	}
+
+	return &LoRaWANUplink{
+		SimpleMessage: SimpleMessage{
+			BrokerDevice: BrokerDevice{Source: ChirpStackSource, DeviceUID: NormalizeDevEUI(event.DeviceInfo.DevEUI)},
+			Payload:      event.Data,
+			Time:         received,
+		},
+		Radio: radio,
+	}, nil
+}
+
+// EncodeChirpStackDownlink gives the body for ChirpStack's application/{id}/device/{devEui}/command/down MQTT topic
+func EncodeChirpStackDownlink(d DownlinkRequest) ([]byte, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		DevEUI    string `json:"devEui"`
+		Confirmed bool   `json:"confirmed"`
+		FPort     uint32 `json:"fPort"`
+		Data      []byte `json:"data"`
+	}{
+		DevEUI:    d.DeviceUID,
+		Confirmed: d.Confirmed,
+		FPort:     d.FPort,
+		Data:      d.Payload,
+	})
+}
@@ -0,0 +1,316 @@
+package stream
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsContentType marks a structured mode event
+	CloudEventsContentType = "application/cloudevents+json"
+	// SimpleMessageEventType is the type of events made from a SimpleMessage
+	SimpleMessageEventType = "com.safecility.stream.message"
+
+	// cloudEventAttributePrefix is the pubsub protocol binding prefix for binary mode attributes
+	cloudEventAttributePrefix = "ce-"
+	contentTypeAttribute      = "content-type"
+)
+
+// CloudEventMode is how an event is carried in a broker message
+type CloudEventMode int
+
+const (
+	// Binary mode carries the event attributes as message attributes and the data as the message body
+	Binary CloudEventMode = iota
+	// Structured mode carries the whole event as json in the message body
+	Structured
+)
+
+var extensionName = regexp.MustCompile(`^[a-z0-9]{1,20}$`)
+
+// CloudEvent is a CloudEvents 1.0 envelope, Data holds the event data as bytes in either mode
+type CloudEvent struct {
+	ID              string
+	Source          string
+	SpecVersion     string
+	Type            string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	Time            time.Time
+	Data            []byte
+	// Extensions are any further context attributes, names must be lowercase alphanumeric and not "data"
+	Extensions map[string]string
+}
+
+// NewCloudEvent wraps a SimpleMessage, the DeviceUID becomes the subject and the payload is carried as binary data
+func NewCloudEvent(m SimpleMessage) CloudEvent {
+	return CloudEvent{
+		ID:              uuid.NewString(),
+		Source:          m.Source,
+		SpecVersion:     CloudEventsSpecVersion,
+		Type:            SimpleMessageEventType,
+		DataContentType: "application/octet-stream",
+		Subject:         m.DeviceUID,
+		Time:            m.Time,
+		Data:            m.Payload,
+	}
+}
+
+// SimpleMessage unwraps an event made with NewCloudEvent, or any event whose subject identifies the device
+func (e CloudEvent) SimpleMessage() (SimpleMessage, error) {
+	if e.Subject == "" {
+		return SimpleMessage{}, fmt.Errorf("event %s has no subject to identify the device", e.ID)
+	}
+	return SimpleMessage{
+		BrokerDevice: BrokerDevice{Source: e.Source, DeviceUID: e.Subject},
+		Payload:      e.Data,
+		Time:         e.Time,
+	}, nil
+}
+
+// Validate checks the required attributes and extension names
+func (e CloudEvent) Validate() error {
+	if e.SpecVersion != CloudEventsSpecVersion {
+		return fmt.Errorf("unsupported specversion %q", e.SpecVersion)
+	}
+	if e.ID == "" {
+		return fmt.Errorf("event id is required")
+	}
+	if e.Source == "" {
+		return fmt.Errorf("event %s source is required", e.ID)
+	}
+	if e.Type == "" {
+		return fmt.Errorf("event %s type is required", e.ID)
+	}
+	for name := range e.Extensions {
+		if !extensionName.MatchString(name) {
+			return fmt.Errorf("event %s extension %q must be 1-20 lowercase letters or digits", e.ID, name)
+		}
+		if name == "data" {
+			return fmt.Errorf("event %s extension %q is reserved for the event data", e.ID, name)
+		}
+		if _, reserved := e.contextAttributes()[name]; reserved {
+			return fmt.Errorf("event %s extension %q clashes with a context attribute", e.ID, name)
+		}
+	}
+	return nil
+}
+
+// contextAttributes gives the non-empty context attributes by their CloudEvents names
+func (e CloudEvent) contextAttributes() map[string]string {
+	attributes := map[string]string{
+		"id":          e.ID,
+		"source":      e.Source,
+		"specversion": e.SpecVersion,
+		"type":        e.Type,
+	}
+	setAttribute(attributes, "datacontenttype", e.DataContentType)
+	setAttribute(attributes, "dataschema", e.DataSchema)
+	setAttribute(attributes, "subject", e.Subject)
+	if !e.Time.IsZero() {
+		attributes["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return attributes
+}
+
+func (e *CloudEvent) setAttribute(name, value string) error {
+	switch name {
+	case "id":
+		e.ID = value
+	case "source":
+		e.Source = value
+	case "specversion":
+		e.SpecVersion = value
+	case "type":
+		e.Type = value
+	case "datacontenttype":
+		e.DataContentType = value
+	case "dataschema":
+		e.DataSchema = value
+	case "subject":
+		e.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("invalid event time %q: %v", value, err)
+		}
+		e.Time = t
+	default:
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]string)
+		}
+		e.Extensions[name] = value
+	}
+	return nil
+}
+
+// isJSON reports whether the data content type says Data can be embedded directly in a structured event
+func (e CloudEvent) isJSON() bool {
+	mediaType := strings.TrimSpace(strings.Split(e.DataContentType, ";")[0])
+	return mediaType == "" || mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// MarshalJSON gives the structured mode encoding, json data is embedded and anything else is sent as data_base64
+func (e CloudEvent) MarshalJSON() ([]byte, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	for k, v := range e.Extensions {
+		fields[k] = v
+	}
+	for k, v := range e.contextAttributes() {
+		fields[k] = v
+	}
+	if e.Data != nil {
+		if e.isJSON() && json.Valid(e.Data) {
+			fields["data"] = json.RawMessage(e.Data)
+		} else {
+			fields["data_base64"] = e.Data
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func (e *CloudEvent) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*e = CloudEvent{}
+	for name, raw := range fields {
+		if name == "data" || name == "data_base64" {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		var s string
+		switch v := value.(type) {
+		case nil:
+			// a null attribute is the same as leaving it out
+			continue
+		case string:
+			s = v
+		case bool:
+			s = strconv.FormatBool(v)
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Errorf("event attribute %q must be a string, number or boolean", name)
+		}
+		if err := e.setAttribute(name, s); err != nil {
+			return err
+		}
+	}
+
+	// data is read once datacontenttype is known, non json data may be embedded as a string e.g. text/plain
+	if raw, ok := fields["data_base64"]; ok {
+		if err := json.Unmarshal(raw, &e.Data); err != nil {
+			return fmt.Errorf("invalid data_base64: %v", err)
+		}
+	} else if raw, ok = fields["data"]; ok {
+		e.Data = raw
+		if !e.isJSON() && isJSONString(raw) {
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return err
+			}
+			e.Data = []byte(s)
+		}
+	}
+	return e.Validate()
+}
+
+func isJSONString(raw json.RawMessage) bool {
+	return len(raw) > 0 && raw[0] == '"'
+}
+
+// PubSubMessage puts the event in a pubsub message using the pubsub protocol binding for mode
+func (e CloudEvent) PubSubMessage(mode CloudEventMode) (*pubsub.Message, error) {
+	if mode == Structured {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		return &pubsub.Message{
+			Data:       data,
+			Attributes: map[string]string{contentTypeAttribute: CloudEventsContentType},
+		}, nil
+	}
+
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]string)
+	for k, v := range e.Extensions {
+		attributes[cloudEventAttributePrefix+k] = v
+	}
+	for k, v := range e.contextAttributes() {
+		if k == "datacontenttype" {
+			attributes[contentTypeAttribute] = v
+			continue
+		}
+		attributes[cloudEventAttributePrefix+k] = v
+	}
+	return &pubsub.Message{Data: e.Data, Attributes: attributes}, nil
+}
+
+// CloudEventFromPubSub reads an event in either mode from a pubsub message
+func CloudEventFromPubSub(m *pubsub.Message) (CloudEvent, error) {
+	var e CloudEvent
+	if strings.HasPrefix(m.Attributes[contentTypeAttribute], CloudEventsContentType) {
+		err := json.Unmarshal(m.Data, &e)
+		return e, err
+	}
+	if _, ok := m.Attributes[cloudEventAttributePrefix+"specversion"]; !ok {
+		return e, fmt.Errorf("message %s is not a cloud event", m.ID)
+	}
+	for k, v := range m.Attributes {
+		if k == contentTypeAttribute {
+			e.DataContentType = v
+			continue
+		}
+		if name, ok := strings.CutPrefix(k, cloudEventAttributePrefix); ok {
+			if err := e.setAttribute(name, v); err != nil {
+				return e, err
+			}
+		}
+	}
+	e.Data = m.Data
+	return e, e.Validate()
+}
+
+// PublishCloudEventToTopic publishes e in the given mode, opts can add further attributes and an ordering key
+func PublishCloudEventToTopic(ctx context.Context, e CloudEvent, mode CloudEventMode, topic *pubsub.Topic, opts ...PublishOption) (*string, error) {
+	if topic == nil {
+		return nil, fmt.Errorf("no topic configured")
+	}
+	message, err := e.PubSubMessage(mode)
+	if err != nil {
+		return nil, err
+	}
+	o := newPublishOptions(opts)
+	for k, v := range o.Attributes {
+		if _, ok := message.Attributes[k]; !ok {
+			message.Attributes[k] = v
+		}
+	}
+	message.OrderingKey = o.OrderingKey
+
+	result, err := publishMessage(ctx, topic, message)
+	if err != nil {
+		return nil, fmt.Errorf("could not publish event to topic %v: %w", topic, err)
+	}
+	return &result, nil
+}
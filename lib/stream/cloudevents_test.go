@@ -0,0 +1,151 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testCloudEvent() CloudEvent {
+	e := NewCloudEvent(SimpleMessage{
+		BrokerDevice: BrokerDevice{Source: "ttn", DeviceUID: "device-1"},
+		Payload:      []byte{0x00, 0xff, 0x10},
+		Time:         time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+	})
+	e.Extensions = map[string]string{"companyuid": "company"}
+	return e
+}
+
+func TestCloudEvent_JSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		event CloudEvent
+	}{
+		{
+			name:  "binary data",
+			event: testCloudEvent(),
+		},
+		{
+			name: "json data",
+			event: CloudEvent{
+				ID:              "1",
+				Source:          "/meters",
+				SpecVersion:     CloudEventsSpecVersion,
+				Type:            "com.safecility.reading",
+				DataContentType: "application/json",
+				Data:            []byte(`{"kWh":1.5}`),
+			},
+		},
+		{
+			name: "text data",
+			event: CloudEvent{
+				ID:              "2",
+				Source:          "/meters",
+				SpecVersion:     CloudEventsSpecVersion,
+				Type:            "com.safecility.note",
+				DataContentType: "text/plain",
+				Data:            []byte("hello"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.event)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var got CloudEvent
+			if err = json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.event) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.event)
+			}
+		})
+	}
+}
+
+func TestCloudEvent_UnmarshalExtensions(t *testing.T) {
+	const base = `"specversion":"1.0","id":"1","source":"/meters","type":"com.safecility.reading"`
+	var e CloudEvent
+	err := json.Unmarshal([]byte(`{`+base+`,"count":1000000,"ratio":0.25,"retried":true,"note":null}`), &e)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := map[string]string{"count": "1000000", "ratio": "0.25", "retried": "true"}
+	if !reflect.DeepEqual(e.Extensions, want) {
+		t.Errorf("Unmarshal() extensions = %v, want %v", e.Extensions, want)
+	}
+	for _, ext := range []string{`"meta":{"a":1}`, `"tags":["a"]`} {
+		if err = json.Unmarshal([]byte(`{`+base+`,`+ext+`}`), &e); err == nil {
+			t.Errorf("Unmarshal() with %s should fail", ext)
+		}
+	}
+}
+
+func TestCloudEvent_PubSubMessage(t *testing.T) {
+	for _, mode := range []CloudEventMode{Binary, Structured} {
+		e := testCloudEvent()
+		m, err := e.PubSubMessage(mode)
+		if err != nil {
+			t.Fatalf("PubSubMessage(%v) error = %v", mode, err)
+		}
+		got, err := CloudEventFromPubSub(m)
+		if err != nil {
+			t.Fatalf("CloudEventFromPubSub(%v) error = %v", mode, err)
+		}
+		if !reflect.DeepEqual(got, e) {
+			t.Errorf("CloudEventFromPubSub(%v) = %+v, want %+v", mode, got, e)
+		}
+		sm, err := got.SimpleMessage()
+		if err != nil || sm.DeviceUID != "device-1" || sm.Source != "ttn" {
+			t.Errorf("SimpleMessage() = %+v, %v", sm, err)
+		}
+	}
+}
+
+func TestCloudEvent_Validate(t *testing.T) {
+	valid := testCloudEvent()
+	tests := []struct {
+		name    string
+		modify  func(e *CloudEvent)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(e *CloudEvent) {}},
+		{name: "no id", modify: func(e *CloudEvent) { e.ID = "" }, wantErr: true},
+		{name: "wrong version", modify: func(e *CloudEvent) { e.SpecVersion = "0.3" }, wantErr: true},
+		{name: "bad extension", modify: func(e *CloudEvent) { e.Extensions = map[string]string{"Company_UID": "x"} }, wantErr: true},
+		{name: "reserved extension", modify: func(e *CloudEvent) { e.Extensions = map[string]string{"subject": "x"} }, wantErr: true},
+		{name: "data extension", modify: func(e *CloudEvent) { e.Extensions = map[string]string{"data": "x"} }, wantErr: true},
+		{name: "data_base64 extension", modify: func(e *CloudEvent) { e.Extensions = map[string]string{"data_base64": "x"} }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := valid
+			tt.modify(&e)
+			if err := e.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPublishCloudEventToTopic(t *testing.T) {
+	client, srv := newTestClient(t)
+	topic, err := client.CreateTopic(context.Background(), "events")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+	defer topic.Stop()
+
+	id, err := PublishCloudEventToTopic(context.Background(), testCloudEvent(), Binary, topic, WithAttribute(AttributeDeviceUID, "device-1"))
+	if err != nil {
+		t.Fatalf("PublishCloudEventToTopic() error = %v", err)
+	}
+	m := srv.Message(*id)
+	if m.Attributes["ce-subject"] != "device-1" || m.Attributes[AttributeDeviceUID] != "device-1" {
+		t.Errorf("PublishCloudEventToTopic() attributes = %v", m.Attributes)
+	}
+}
@@ -0,0 +1,155 @@
+package stream
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Decoder turns the data of a received message into the type a Handler expects
+type Decoder[T any] func(data []byte) (T, error)
+
+// Handler processes a decoded message - returning nil acks the message, an error nacks it for redelivery
+// unless the error is wrapped with Permanent
+type Handler[T any] func(ctx context.Context, m T) error
+
+// JSONDecoder decodes json into T, this is the usual decoder for SimpleMessage streams
+func JSONDecoder[T any]() Decoder[T] {
+	return func(data []byte) (T, error) {
+		var m T
+		if err := json.Unmarshal(data, &m); err != nil {
+			return m, fmt.Errorf("could not unmarshall message: %v", err)
+		}
+		return m, nil
+	}
+}
+
+// ProtoDecoder decodes proto messages published with PublishProtoToTopic, newT must return an empty message to fill
+func ProtoDecoder[T proto.Message](encoding pubsub.SchemaEncoding, newT func() T) Decoder[T] {
+	return func(data []byte) (T, error) {
+		m := newT()
+		var err error
+		switch encoding {
+		case pubsub.EncodingBinary:
+			err = proto.Unmarshal(data, m)
+		case pubsub.EncodingJSON:
+			err = protojson.Unmarshal(data, m)
+		default:
+			err = fmt.Errorf("invalid encoding: %v", encoding)
+		}
+		if err != nil {
+			return m, fmt.Errorf("could not unmarshall proto message: %v", err)
+		}
+		return m, nil
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a Handler error as not worth retrying - the message is logged and acked rather than nacked
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// ConsumerConfig controls how a Consumer pulls from its subscription
+type ConsumerConfig struct {
+	// Concurrency is the maximum number of handlers running at once, 0 keeps the pubsub default
+	Concurrency int `json:"concurrency"`
+	// NumGoroutines is the number of streaming pull goroutines, 0 keeps the pubsub default
+	NumGoroutines int `json:"numGoroutines"`
+	// AckUndecodable acks messages that cannot be decoded instead of nacking them - without a dead letter policy
+	// an undecodable message would otherwise be redelivered forever
+	AckUndecodable bool `json:"ackUndecodable"`
+}
+
+// Consumer pulls messages from a subscription, decodes them and passes them to a typed Handler
+type Consumer[T any] struct {
+	subscription *pubsub.Subscription
+	decode       Decoder[T]
+	handler      Handler[T]
+	config       ConsumerConfig
+}
+
+func NewConsumer[T any](subscription *pubsub.Subscription, decode Decoder[T], handler Handler[T], config ConsumerConfig) *Consumer[T] {
+	return &Consumer[T]{
+		subscription: subscription,
+		decode:       decode,
+		handler:      handler,
+		config:       config,
+	}
+}
+
+// NewSimpleMessageConsumer is a Consumer of json encoded SimpleMessage
+func NewSimpleMessageConsumer(subscription *pubsub.Subscription, handler Handler[SimpleMessage], config ConsumerConfig) *Consumer[SimpleMessage] {
+	return NewConsumer(subscription, JSONDecoder[SimpleMessage](), handler, config)
+}
+
+// Receive blocks pulling messages until ctx is cancelled or the subscription fails.
+// On cancel Receive waits for running handlers to finish before returning nil.
+func (c *Consumer[T]) Receive(ctx context.Context) error {
+	if c.subscription == nil {
+		return fmt.Errorf("no subscription configured")
+	}
+	if c.config.Concurrency > 0 {
+		c.subscription.ReceiveSettings.MaxOutstandingMessages = c.config.Concurrency
+	}
+	if c.config.NumGoroutines > 0 {
+		c.subscription.ReceiveSettings.NumGoroutines = c.config.NumGoroutines
+	}
+
+	err := c.subscription.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		if c.handle(ctx, msg.ID, msg.Data) {
+			msg.Ack()
+		} else {
+			msg.Nack()
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("subscription %s receive failed: %w", c.subscription.ID(), err)
+	}
+	return nil
+}
+
+// handle decodes and runs the handler on a single message, reporting whether the message should be acked
+func (c *Consumer[T]) handle(ctx context.Context, id string, data []byte) bool {
+	m, err := c.decode(data)
+	if err != nil {
+		log.Err(err).Str("messageID", id).Bool("acked", c.config.AckUndecodable).Msg("could not decode message")
+		return c.config.AckUndecodable
+	}
+
+	err = c.handler(ctx, m)
+	if err == nil {
+		return true
+	}
+	if IsPermanent(err) {
+		log.Err(err).Str("messageID", id).Msg("dropping message after permanent handler error")
+		return true
+	}
+	log.Debug().Err(err).Str("messageID", id).Msg("handler failed, message will be redelivered")
+	return false
+}
@@ -0,0 +1,126 @@
+package stream
+
+import (
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestClient starts an in-process fake pubsub server so tests don't need the emulator
+func newTestClient(t *testing.T) (*pubsub.Client, *pstest.Server) {
+	t.Helper()
+	srv := pstest.NewServer()
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("could not dial fake server: %v", err)
+	}
+	client, err := pubsub.NewClient(context.Background(), "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = srv.Close()
+	})
+	return client, srv
+}
+
+func newTestSubscription(t *testing.T, client *pubsub.Client, id string) (*pubsub.Topic, *pubsub.Subscription) {
+	t.Helper()
+	ctx := context.Background()
+	topic, err := client.CreateTopic(ctx, id)
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+	sub, err := client.CreateSubscription(ctx, id, pubsub.SubscriptionConfig{Topic: topic})
+	if err != nil {
+		t.Fatalf("could not create subscription: %v", err)
+	}
+	return topic, sub
+}
+
+func TestConsumer_Receive(t *testing.T) {
+	client, srv := newTestClient(t)
+	_, sub := newTestSubscription(t, client, "consumer")
+
+	good, _ := json.Marshal(SimpleMessage{BrokerDevice: BrokerDevice{Source: "test", DeviceUID: "ok"}})
+	permanent, _ := json.Marshal(SimpleMessage{BrokerDevice: BrokerDevice{Source: "test", DeviceUID: "permanent"}})
+	okID := srv.Publish("projects/test-project/topics/consumer", good, nil)
+	permanentID := srv.Publish("projects/test-project/topics/consumer", permanent, nil)
+	badID := srv.Publish("projects/test-project/topics/consumer", []byte("not json"), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	seen := map[string]int{}
+	c := NewSimpleMessageConsumer(sub, func(ctx context.Context, m SimpleMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[m.DeviceUID]++
+		if m.DeviceUID == "permanent" {
+			return Permanent(fmt.Errorf("bad reading"))
+		}
+		return nil
+	}, ConsumerConfig{Concurrency: 2, AckUndecodable: true})
+
+	done := make(chan error)
+	go func() { done <- c.Receive(ctx) }()
+
+	deadline := time.After(10 * time.Second)
+	for {
+		acked := 0
+		for _, id := range []string{okID, permanentID, badID} {
+			if m := srv.Message(id); m != nil && m.Acks > 0 {
+				acked++
+			}
+		}
+		if acked == 3 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for acks, seen %v", seen)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Receive() error = %v", err)
+	}
+	if seen["ok"] != 1 || seen["permanent"] != 1 {
+		t.Errorf("Receive() handled %v, want each message once", seen)
+	}
+}
+
+func TestConsumer_handle(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           string
+		handlerErr     error
+		ackUndecodable bool
+		want           bool
+	}{
+		{name: "handled", data: `{"DeviceUID":"a"}`, want: true},
+		{name: "retry", data: `{"DeviceUID":"a"}`, handlerErr: fmt.Errorf("busy"), want: false},
+		{name: "permanent", data: `{"DeviceUID":"a"}`, handlerErr: Permanent(fmt.Errorf("bad")), want: true},
+		{name: "undecodable nacked", data: `{`, want: false},
+		{name: "undecodable acked", data: `{`, ackUndecodable: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewSimpleMessageConsumer(nil, func(ctx context.Context, m SimpleMessage) error {
+				return tt.handlerErr
+			}, ConsumerConfig{AckUndecodable: tt.ackUndecodable})
+			if got := c.handle(context.Background(), "id", []byte(tt.data)); got != tt.want {
+				t.Errorf("handle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
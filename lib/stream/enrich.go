@@ -0,0 +1,127 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/safecility/go/lib"
+	"time"
+)
+
+// AttributeQuarantineReason says why a message was sent to a quarantine topic
+const AttributeQuarantineReason = "quarantineReason"
+
+// MetaLookup resolves a DeviceUID to its meta, unknown devices return an error wrapping lib.ErrDeviceNotFound.
+// cache.MetaCache Get is a MetaLookup.
+type MetaLookup func(ctx context.Context, uid string) (*lib.DeviceMeta, error)
+
+// EnrichedMessage is a SimpleMessage with the device's meta attached for later pipeline stages
+type EnrichedMessage struct {
+	lib.Device
+	Source  string
+	Payload []byte
+	Time    time.Time
+}
+
+// SimpleMessage strips the meta from an EnrichedMessage
+func (m EnrichedMessage) SimpleMessage() SimpleMessage {
+	return SimpleMessage{
+		BrokerDevice: BrokerDevice{Source: m.Source, DeviceUID: m.DeviceUID},
+		Payload:      m.Payload,
+		Time:         m.Time,
+	}
+}
+
+// UnknownDevicePolicy decides what an Enricher does with messages from devices the lookup doesn't know
+type UnknownDevicePolicy int
+
+const (
+	// DropUnknown acks and discards the message
+	DropUnknown UnknownDevicePolicy = iota
+	// PassUnknown hands the message on with a nil DeviceMeta
+	PassUnknown
+	// QuarantineUnknown publishes the original SimpleMessage to the quarantine Publisher
+	QuarantineUnknown
+)
+
+func (p UnknownDevicePolicy) String() string {
+	switch p {
+	case DropUnknown:
+		return "drop"
+	case PassUnknown:
+		return "pass"
+	case QuarantineUnknown:
+		return "quarantine"
+	default:
+		return fmt.Sprintf("UnknownDevicePolicy(%d)", int(p))
+	}
+}
+
+type EnricherConfig struct {
+	Unknown UnknownDevicePolicy
+	// Quarantine receives messages from unknown devices under QuarantineUnknown
+	Quarantine Publisher
+}
+
+// Enricher attaches DeviceMeta to SimpleMessages
+type Enricher struct {
+	lookup MetaLookup
+	config EnricherConfig
+}
+
+func NewEnricher(lookup MetaLookup, config EnricherConfig) (*Enricher, error) {
+	if lookup == nil {
+		return nil, fmt.Errorf("enricher needs a lookup")
+	}
+	if config.Unknown == QuarantineUnknown && config.Quarantine == nil {
+		return nil, fmt.Errorf("quarantine policy needs a quarantine publisher")
+	}
+	return &Enricher{lookup: lookup, config: config}, nil
+}
+
+// Enrich looks up m's device, the returned message is nil when an unknown device was dropped or quarantined.
+// Lookup failures other than lib.ErrDeviceNotFound are returned so the message can be retried.
+func (e *Enricher) Enrich(ctx context.Context, m SimpleMessage) (*EnrichedMessage, error) {
+	enriched := &EnrichedMessage{
+		Device:  lib.Device{DeviceUID: m.DeviceUID},
+		Source:  m.Source,
+		Payload: m.Payload,
+		Time:    m.Time,
+	}
+	meta, err := e.lookup(ctx, m.DeviceUID)
+	if err == nil {
+		enriched.DeviceMeta = meta
+		return enriched, nil
+	}
+	if !errors.Is(err, lib.ErrDeviceNotFound) {
+		return nil, fmt.Errorf("could not look up device %s: %w", m.DeviceUID, err)
+	}
+
+	switch e.config.Unknown {
+	case PassUnknown:
+		return enriched, nil
+	case QuarantineUnknown:
+		_, err = e.config.Quarantine.Publish(ctx, m, WithAttributes(m.BrokerDevice.Attributes()),
+			WithAttribute(AttributeQuarantineReason, "unknown device"))
+		if err != nil {
+			return nil, fmt.Errorf("could not quarantine message from %s: %w", m.DeviceUID, err)
+		}
+		log.Debug().Str("deviceUID", m.DeviceUID).Msg("quarantined message from unknown device")
+		return nil, nil
+	default:
+		log.Debug().Str("deviceUID", m.DeviceUID).Msg("dropped message from unknown device")
+		return nil, nil
+	}
+}
+
+// Handler wraps next as a SimpleMessage Handler for use with NewSimpleMessageConsumer
+func (e *Enricher) Handler(next Handler[EnrichedMessage]) Handler[SimpleMessage] {
+	return func(ctx context.Context, m SimpleMessage) error {
+		enriched, err := e.Enrich(ctx, m)
+		if err != nil || enriched == nil {
+			return err
+		}
+		return next(ctx, *enriched)
+	}
+}
@@ -0,0 +1,89 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"testing"
+	"time"
+)
+
+func testLookup(ctx context.Context, uid string) (*lib.DeviceMeta, error) {
+	switch uid {
+	case "known":
+		return &lib.DeviceMeta{DeviceTag: "cooler-4b", CompanyUID: "c1"}, nil
+	case "broken":
+		return nil, errors.New("store unavailable")
+	default:
+		return nil, fmt.Errorf("%w: %s", lib.ErrDeviceNotFound, uid)
+	}
+}
+
+func TestEnricher_Handler(t *testing.T) {
+	tests := []struct {
+		name           string
+		uid            string
+		policy         UnknownDevicePolicy
+		wantHandled    bool
+		wantMeta       bool
+		wantQuarantine bool
+		wantErr        bool
+	}{
+		{name: "known", uid: "known", wantHandled: true, wantMeta: true},
+		{name: "unknown dropped", uid: "other", policy: DropUnknown},
+		{name: "unknown passed", uid: "other", policy: PassUnknown, wantHandled: true},
+		{name: "unknown quarantined", uid: "other", policy: QuarantineUnknown, wantQuarantine: true},
+		{name: "lookup failure", uid: "broken", policy: PassUnknown, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quarantine := NewMemoryPublisher()
+			e, err := NewEnricher(testLookup, EnricherConfig{Unknown: tt.policy, Quarantine: quarantine})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := SimpleMessage{
+				BrokerDevice: BrokerDevice{Source: "test", DeviceUID: tt.uid},
+				Payload:      []byte{0x01},
+				Time:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+
+			var handled *EnrichedMessage
+			err = e.Handler(func(ctx context.Context, m EnrichedMessage) error {
+				handled = &m
+				return nil
+			})(context.Background(), m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Handler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (handled != nil) != tt.wantHandled {
+				t.Fatalf("Handler() handled = %v, want %v", handled, tt.wantHandled)
+			}
+			if handled != nil {
+				if (handled.DeviceMeta != nil) != tt.wantMeta {
+					t.Errorf("Handler() meta = %v, want meta %v", handled.DeviceMeta, tt.wantMeta)
+				}
+				if handled.SimpleMessage().DeviceUID != tt.uid || handled.Source != "test" {
+					t.Errorf("Handler() message = %+v", handled)
+				}
+			}
+			published := quarantine.Messages()
+			if (len(published) == 1) != tt.wantQuarantine {
+				t.Fatalf("quarantined %d messages, want quarantine %v", len(published), tt.wantQuarantine)
+			}
+			if tt.wantQuarantine && (published[0].Attributes[AttributeQuarantineReason] == "" || published[0].OrderingKey != "") {
+				t.Errorf("quarantined message has attributes %v", published[0].Attributes)
+			}
+		})
+	}
+}
+
+func TestNewEnricher(t *testing.T) {
+	if _, err := NewEnricher(testLookup, EnricherConfig{Unknown: QuarantineUnknown}); err == nil {
+		t.Errorf("NewEnricher() should refuse quarantine without a publisher")
+	}
+	if _, err := NewEnricher(nil, EnricherConfig{}); err == nil {
+		t.Errorf("NewEnricher() should refuse a nil lookup")
+	}
+}
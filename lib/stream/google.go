@@ -12,59 +12,89 @@ import (
 
 // PublishToTopic just wraps json marshalling of an interface and Publish to a google pubsub Topic returning the result
 // of the Publish method
-func PublishToTopic(m interface{}, topic *pubsub.Topic) (*string, error) {
+func PublishToTopic(m interface{}, topic *pubsub.Topic, opts ...PublishOption) (*string, error) {
+	return PublishToTopicContext(context.Background(), m, topic, opts...)
+}
+
+// PublishToTopicContext is PublishToTopic bounded by ctx - cancelling ctx or passing its deadline stops waiting
+// for the server ack
+func PublishToTopicContext(ctx context.Context, m interface{}, topic *pubsub.Topic, opts ...PublishOption) (*string, error) {
 	if topic == nil {
 		return nil, fmt.Errorf("no topic configured")
 	}
-	ctx := context.Background()
 
-	jsonBytes, err := json.Marshal(m)
+	jsonBytes, err := marshalJSON(m)
 	if err != nil {
-		return nil, fmt.Errorf("could not marshall message %v: %v", m, err)
+		return nil, err
 	}
 
-	message := &pubsub.Message{
-		Data:        jsonBytes,
-		PublishTime: time.Now(),
+	result, err := publishMessage(ctx, topic, newMessage(jsonBytes, time.Now(), opts))
+	if err != nil {
+		return nil, fmt.Errorf("could not publish json to topic %v: %w", topic, err)
 	}
 
-	result, err := topic.Publish(ctx, message).Get(ctx)
+	return &result, nil
+}
+
+func PublishProtoToTopic(m proto.Message, encoding pubsub.SchemaEncoding, topic *pubsub.Topic, opts ...PublishOption) (*string, error) {
+	return PublishProtoToTopicContext(context.Background(), m, encoding, topic, opts...)
+}
+
+// PublishProtoToTopicContext is PublishProtoToTopic bounded by ctx
+func PublishProtoToTopicContext(ctx context.Context, m proto.Message, encoding pubsub.SchemaEncoding, topic *pubsub.Topic, opts ...PublishOption) (*string, error) {
+	if topic == nil {
+		return nil, fmt.Errorf("no topic configured")
+	}
+	msg, err := marshalProto(m, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := publishMessage(ctx, topic, newMessage(msg, time.Time{}, opts))
 	if err != nil {
-		return nil, fmt.Errorf("could not publish json to topic %v: %v", topic, err)
+		return nil, fmt.Errorf("could not publish json to topic %v: %w", topic, err)
 	}
 
 	return &result, nil
 }
 
-func PublishProtoToTopic(m proto.Message, encoding pubsub.SchemaEncoding, topic *pubsub.Topic) (*string, error) {
-	var msg []byte
-	var err error
+// publishMessage publishes and waits for the server ack.
+// A failed message with an OrderingKey pauses publishing for that key, as the caller is waiting on this message
+// nothing later for the key has been sent so the key is resumed to let the caller retry.
+func publishMessage(ctx context.Context, topic *pubsub.Topic, message *pubsub.Message) (string, error) {
+	result, err := topic.Publish(ctx, message).Get(ctx)
+	if err != nil && message.OrderingKey != "" {
+		topic.ResumePublish(message.OrderingKey)
+	}
+	return result, err
+}
+
+func marshalJSON(m interface{}) ([]byte, error) {
+	jsonBytes, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshall message %v: %v", m, err)
+	}
+	return jsonBytes, nil
+}
 
+// marshalProto encodes m as required by a topic schema's encoding
+func marshalProto(m proto.Message, encoding pubsub.SchemaEncoding) ([]byte, error) {
 	switch encoding {
 	case pubsub.EncodingBinary:
-		msg, err = proto.Marshal(m)
+		msg, err := proto.Marshal(m)
 		if err != nil {
 			return nil, fmt.Errorf("proto.Marshal err: %v", err)
 		}
+		return msg, nil
 	case pubsub.EncodingJSON:
-		msg, err = protojson.Marshal(m)
+		msg, err := protojson.Marshal(m)
 		if err != nil {
 			return nil, fmt.Errorf("protojson.Marshal err: %v", err)
 		}
+		return msg, nil
 	default:
 		return nil, fmt.Errorf("invalid encoding: %v", encoding)
 	}
-
-	ctx := context.Background()
-	result, err := topic.Publish(ctx, &pubsub.Message{
-		Data: msg,
-	}).Get(ctx)
-
-	if err != nil {
-		return nil, fmt.Errorf("could not publish json to topic %v: %v", topic, err)
-	}
-
-	return &result, nil
 }
 
 // GetDefaultSubscriptionConfig TODO fill in better defaults - make duration deployment dependant
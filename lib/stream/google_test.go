@@ -2,6 +2,7 @@ package stream
 
 import (
 	"cloud.google.com/go/pubsub"
+	"context"
 	"reflect"
 	"testing"
 )
@@ -41,3 +42,43 @@ func TestPublishToTopic(t *testing.T) {
 		})
 	}
 }
+
+func TestPublishToTopicContext(t *testing.T) {
+	client, _ := newTestClient(t)
+	topic, err := client.CreateTopic(context.Background(), "publish-context")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+	defer topic.Stop()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr bool
+	}{
+		{
+			name: "published",
+			ctx:  context.Background(),
+		},
+		{
+			name:    "cancelled",
+			ctx:     cancelled,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PublishToTopicContext(tt.ctx, SimpleMessage{}, topic)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PublishToTopicContext() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && (got == nil || *got == "") {
+				t.Errorf("PublishToTopicContext() got no message id")
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package stream
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"github.com/safecility/go/lib"
+)
+
+// LifecycleFilter passes on messages from devices in one of the given states, other messages are acked and dropped.
+// Devices without meta or status count as lib.Active.
+func LifecycleFilter(next Handler[EnrichedMessage], states ...lib.LifecycleState) Handler[EnrichedMessage] {
+	allowed := make(map[lib.LifecycleState]bool, len(states))
+	for _, s := range states {
+		allowed[s] = true
+	}
+	return func(ctx context.Context, m EnrichedMessage) error {
+		state := lib.Active
+		if m.DeviceMeta != nil && m.Status != "" {
+			state = m.Status
+		}
+		if !allowed[state] {
+			log.Debug().Str("deviceUID", m.DeviceUID).Str("status", string(state)).Msg("dropped message by lifecycle")
+			return nil
+		}
+		return next(ctx, m)
+	}
+}
+
+// ReportingOnly passes on messages from devices whose readings belong in analytics
+func ReportingOnly(next Handler[EnrichedMessage]) Handler[EnrichedMessage] {
+	return LifecycleFilter(next, lib.Active)
+}
@@ -0,0 +1,50 @@
+package stream
+
+import (
+	"context"
+	"github.com/safecility/go/lib"
+	"testing"
+)
+
+func TestReportingOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		meta *lib.DeviceMeta
+		want bool
+	}{
+		{name: "no meta", want: true},
+		{name: "no status", meta: &lib.DeviceMeta{}, want: true},
+		{name: "active", meta: &lib.DeviceMeta{Status: lib.Active}, want: true},
+		{name: "maintenance", meta: &lib.DeviceMeta{Status: lib.Maintenance}},
+		{name: "decommissioned", meta: &lib.DeviceMeta{Status: lib.Decommissioned}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bool
+			err := ReportingOnly(func(ctx context.Context, m EnrichedMessage) error {
+				got = true
+				return nil
+			})(context.Background(), EnrichedMessage{Device: lib.Device{DeviceUID: "a", DeviceMeta: tt.meta}})
+			if err != nil || got != tt.want {
+				t.Errorf("ReportingOnly() passed = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLifecycleFilter(t *testing.T) {
+	var passed []lib.LifecycleState
+	handler := LifecycleFilter(func(ctx context.Context, m EnrichedMessage) error {
+		passed = append(passed, m.Status)
+		return nil
+	}, lib.Active, lib.Maintenance)
+	for _, status := range []lib.LifecycleState{lib.Provisioned, lib.Maintenance, lib.Faulty, lib.Active} {
+		m := EnrichedMessage{Device: lib.Device{DeviceUID: "a", DeviceMeta: &lib.DeviceMeta{Status: status}}}
+		if err := handler(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(passed) != 2 || passed[0] != lib.Maintenance || passed[1] != lib.Active {
+		t.Errorf("LifecycleFilter() passed %v, want maintenance and active", passed)
+	}
+}
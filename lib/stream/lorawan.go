@@ -0,0 +1,83 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sources of messages decoded from LoRaWAN network servers
+const (
+	TheThingsStackSource = "tts"
+	ChirpStackSource     = "chirpstack"
+)
+
+// GatewayMetadata is a gateway's reception of an uplink
+type GatewayMetadata struct {
+	GatewayID string  `json:"gatewayID"`
+	RSSI      float64 `json:"rssi"`
+	SNR       float64 `json:"snr"`
+}
+
+// RadioMetadata is the LoRaWAN detail of an uplink that does not fit in a SimpleMessage
+type RadioMetadata struct {
+	DevEUI          string            `json:"devEUI"`
+	DevAddr         string            `json:"devAddr,omitempty"`
+	FPort           uint32            `json:"fPort"`
+	FCnt            uint32            `json:"fCnt"`
+	Frequency       uint64            `json:"frequency,omitempty"`
+	SpreadingFactor uint32            `json:"spreadingFactor,omitempty"`
+	Bandwidth       uint32            `json:"bandwidth,omitempty"`
+	Gateways        []GatewayMetadata `json:"gateways,omitempty"`
+}
+
+// BestGateway is the gateway that heard the uplink with the strongest signal
+func (r RadioMetadata) BestGateway() (GatewayMetadata, bool) {
+	if len(r.Gateways) == 0 {
+		return GatewayMetadata{}, false
+	}
+	best := r.Gateways[0]
+	for _, g := range r.Gateways[1:] {
+		if g.RSSI > best.RSSI {
+			best = g
+		}
+	}
+	return best, true
+}
+
+// GatewayIDs lists the gateways that heard the uplink
+func (r RadioMetadata) GatewayIDs() []string {
+	ids := make([]string, len(r.Gateways))
+	for i, g := range r.Gateways {
+		ids[i] = g.GatewayID
+	}
+	return ids
+}
+
+// LoRaWANUplink is a SimpleMessage decoded from a network server uplink event along with its radio metadata
+type LoRaWANUplink struct {
+	SimpleMessage
+	Radio RadioMetadata
+}
+
+// DownlinkRequest is a network server independent request to send a payload to a device
+type DownlinkRequest struct {
+	DeviceUID string
+	FPort     uint32
+	Payload   []byte
+	Confirmed bool
+}
+
+func (d DownlinkRequest) validate() error {
+	if d.DeviceUID == "" {
+		return fmt.Errorf("downlink needs a device uid")
+	}
+	if d.FPort == 0 || d.FPort > 223 {
+		return fmt.Errorf("downlink fPort %d must be between 1 and 223", d.FPort)
+	}
+	return nil
+}
+
+// NormalizeDevEUI gives the DeviceUID used for a DevEUI - network servers disagree on case so we use lowercase hex
+func NormalizeDevEUI(devEUI string) string {
+	return strings.ToLower(strings.TrimSpace(devEUI))
+}
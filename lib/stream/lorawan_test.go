@@ -0,0 +1,147 @@
+package stream
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const ttsUplink = `{
+  "end_device_ids": {
+    "device_id": "cooler-4b",
+    "application_ids": {"application_id": "safecility"},
+    "dev_eui": "0004A30B001C0530",
+    "dev_addr": "00BCB929"
+  },
+  "received_at": "2024-03-01T12:00:01Z",
+  "uplink_message": {
+    "f_port": 2,
+    "f_cnt": 42,
+    "frm_payload": "AQID",
+    "rx_metadata": [
+      {"gateway_ids": {"gateway_id": "gw-1"}, "rssi": -90, "snr": 4.5},
+      {"gateway_ids": {"gateway_id": "gw-2"}, "rssi": -60, "snr": 9.25}
+    ],
+    "settings": {
+      "data_rate": {"lora": {"bandwidth": 125000, "spreading_factor": 7}},
+      "frequency": "868100000"
+    },
+    "received_at": "2024-03-01T12:00:00Z"
+  }
+}`
+
+const chirpStackUplink = `{
+  "deduplicationId": "3ac7e3c4-4401-4b8d-9386-a5c902f9202d",
+  "time": "2024-03-01T12:00:00.5+00:00",
+  "deviceInfo": {"deviceName": "cooler-4b", "devEui": "0004a30b001c0530"},
+  "devAddr": "00bcb929",
+  "fCnt": 42,
+  "fPort": 2,
+  "data": "AQID",
+  "rxInfo": [{"gatewayId": "0016c001f153a14c", "rssi": -36, "snr": 10.5}],
+  "txInfo": {"frequency": 868100000, "modulation": {"lora": {"bandwidth": 125000, "spreadingFactor": 11}}}
+}`
+
+func TestDecodeTTSUplink(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *LoRaWANUplink
+		wantErr bool
+	}{
+		{
+			name: "uplink",
+			data: ttsUplink,
+			want: &LoRaWANUplink{
+				SimpleMessage: SimpleMessage{
+					BrokerDevice: BrokerDevice{Source: TheThingsStackSource, DeviceUID: "0004a30b001c0530"},
+					Payload:      []byte{1, 2, 3},
+					Time:         time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+				},
+				Radio: RadioMetadata{
+					DevEUI:          "0004A30B001C0530",
+					DevAddr:         "00BCB929",
+					FPort:           2,
+					FCnt:            42,
+					Frequency:       868100000,
+					SpreadingFactor: 7,
+					Bandwidth:       125000,
+					Gateways: []GatewayMetadata{
+						{GatewayID: "gw-1", RSSI: -90, SNR: 4.5},
+						{GatewayID: "gw-2", RSSI: -60, SNR: 9.25},
+					},
+				},
+			},
+		},
+		{
+			name:    "join accept",
+			data:    `{"end_device_ids": {"device_id": "cooler-4b"}, "join_accept": {}}`,
+			wantErr: true,
+		},
+		{
+			name:    "not json",
+			data:    `uplink`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeTTSUplink([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeTTSUplink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeTTSUplink() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeChirpStackUplink(t *testing.T) {
+	got, err := DecodeChirpStackUplink([]byte(chirpStackUplink))
+	if err != nil {
+		t.Fatalf("DecodeChirpStackUplink() error = %v", err)
+	}
+	if got.DeviceUID != "0004a30b001c0530" || got.Source != ChirpStackSource {
+		t.Errorf("DecodeChirpStackUplink() device = %+v", got.BrokerDevice)
+	}
+	if !reflect.DeepEqual(got.Payload, []byte{1, 2, 3}) || got.Radio.FPort != 2 || got.Radio.FCnt != 42 {
+		t.Errorf("DecodeChirpStackUplink() payload = %v, radio %+v", got.Payload, got.Radio)
+	}
+	if got.Radio.SpreadingFactor != 11 || got.Radio.Frequency != 868100000 {
+		t.Errorf("DecodeChirpStackUplink() radio = %+v", got.Radio)
+	}
+	if !got.Time.Equal(time.Date(2024, 3, 1, 12, 0, 0, 500000000, time.UTC)) {
+		t.Errorf("DecodeChirpStackUplink() time = %v", got.Time)
+	}
+	if best, _ := got.Radio.BestGateway(); best.GatewayID != "0016c001f153a14c" {
+		t.Errorf("BestGateway() = %+v", best)
+	}
+}
+
+func TestEncodeDownlink(t *testing.T) {
+	d := DownlinkRequest{DeviceUID: "0004a30b001c0530", FPort: 10, Payload: []byte{0xff}}
+
+	tts, err := EncodeTTSDownlink(d)
+	if err != nil {
+		t.Fatalf("EncodeTTSDownlink() error = %v", err)
+	}
+	if string(tts) != `{"downlinks":[{"f_port":10,"frm_payload":"/w==","priority":"NORMAL"}]}` {
+		t.Errorf("EncodeTTSDownlink() = %s", tts)
+	}
+
+	cs, err := EncodeChirpStackDownlink(d)
+	if err != nil {
+		t.Fatalf("EncodeChirpStackDownlink() error = %v", err)
+	}
+	var body map[string]interface{}
+	_ = json.Unmarshal(cs, &body)
+	if body["devEui"] != d.DeviceUID || body["data"] != "/w==" || body["fPort"] != float64(10) {
+		t.Errorf("EncodeChirpStackDownlink() = %s", cs)
+	}
+
+	if _, err = EncodeTTSDownlink(DownlinkRequest{DeviceUID: "x", FPort: 0}); err == nil {
+		t.Errorf("EncodeTTSDownlink() should refuse fPort 0")
+	}
+}
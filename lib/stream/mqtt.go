@@ -0,0 +1,421 @@
+package stream
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog/log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type MQTTVersion uint
+
+const (
+	MQTTv311 MQTTVersion = 4
+	MQTTv5   MQTTVersion = 5
+)
+
+// DeviceUIDPlaceholder marks the topic level that holds the DeviceUID in a TopicTemplate
+const DeviceUIDPlaceholder = "{deviceUID}"
+
+// MQTTConfig configures an MQTTClient for either protocol version
+type MQTTConfig struct {
+	// Brokers are urls such as tcp://host:1883 or ssl://host:8883
+	Brokers  []string    `json:"brokers"`
+	ClientID string      `json:"clientID"`
+	Username string      `json:"username"`
+	Password string      `json:"-"`
+	Version  MQTTVersion `json:"version"`
+	QoS      byte        `json:"qos"`
+	// CleanSession discards the session on connect, leave false with a fixed ClientID for a persistent session
+	CleanSession bool `json:"cleanSession"`
+	// SessionExpiry is how long a v5 broker keeps a persistent session after disconnect
+	SessionExpiry  time.Duration `json:"sessionExpiry"`
+	KeepAlive      time.Duration `json:"keepAlive"`
+	ConnectTimeout time.Duration `json:"connectTimeout"`
+	TLS            *tls.Config   `json:"-"`
+	// Source is set as the BrokerDevice.Source of received messages
+	Source string `json:"source"`
+}
+
+func (c MQTTConfig) validate() error {
+	if len(c.Brokers) == 0 {
+		return fmt.Errorf("no mqtt brokers configured")
+	}
+	if c.Version != MQTTv311 && c.Version != MQTTv5 {
+		return fmt.Errorf("unsupported mqtt version %d", c.Version)
+	}
+	if c.QoS > 2 {
+		return fmt.Errorf("invalid mqtt qos %d", c.QoS)
+	}
+	if !c.CleanSession && c.ClientID == "" {
+		return fmt.Errorf("a persistent mqtt session needs a client id")
+	}
+	return nil
+}
+
+// TopicTemplate maps between MQTT topics and DeviceUIDs, e.g. application/+/device/{deviceUID}/event/up
+type TopicTemplate struct {
+	levels      []string
+	deviceLevel int
+}
+
+func NewTopicTemplate(template string) (TopicTemplate, error) {
+	t := TopicTemplate{levels: strings.Split(template, "/"), deviceLevel: -1}
+	for i, level := range t.levels {
+		switch {
+		case level == DeviceUIDPlaceholder:
+			if t.deviceLevel >= 0 {
+				return t, fmt.Errorf("topic template %s has more than one %s", template, DeviceUIDPlaceholder)
+			}
+			t.deviceLevel = i
+		case level == "+":
+		case level == "#":
+			if i != len(t.levels)-1 {
+				return t, fmt.Errorf("topic template %s has # before the last level", template)
+			}
+		case strings.ContainsAny(level, "{}#+"):
+			return t, fmt.Errorf("topic template %s has an invalid level %q", template, level)
+		}
+	}
+	if t.deviceLevel < 0 {
+		return t, fmt.Errorf("topic template %s has no %s", template, DeviceUIDPlaceholder)
+	}
+	return t, nil
+}
+
+func (t TopicTemplate) String() string {
+	return strings.Join(t.levels, "/")
+}
+
+// Filter is the subscription filter for the template, the DeviceUID level matches any device
+func (t TopicTemplate) Filter() string {
+	levels := append([]string{}, t.levels...)
+	levels[t.deviceLevel] = "+"
+	return strings.Join(levels, "/")
+}
+
+// DeviceUID extracts the DeviceUID from a topic, reporting false if the topic does not match the template
+func (t TopicTemplate) DeviceUID(topic string) (string, bool) {
+	levels := strings.Split(topic, "/")
+	for i, level := range t.levels {
+		if level == "#" {
+			break
+		}
+		if i >= len(levels) {
+			return "", false
+		}
+		if level != "+" && level != DeviceUIDPlaceholder && level != levels[i] {
+			return "", false
+		}
+		if i == len(t.levels)-1 && len(levels) != len(t.levels) {
+			return "", false
+		}
+	}
+	deviceUID := levels[t.deviceLevel]
+	return deviceUID, deviceUID != ""
+}
+
+// Topic gives the topic to publish to for a device, templates with wildcards cannot be published to
+func (t TopicTemplate) Topic(deviceUID string) (string, error) {
+	if deviceUID == "" || strings.ContainsAny(deviceUID, "/#+") {
+		return "", fmt.Errorf("invalid device uid %q for an mqtt topic", deviceUID)
+	}
+	levels := append([]string{}, t.levels...)
+	for i, level := range levels {
+		if level == "+" || level == "#" {
+			return "", fmt.Errorf("cannot publish to wildcard topic template %s", t)
+		}
+		if i == t.deviceLevel {
+			levels[i] = deviceUID
+		}
+	}
+	return strings.Join(levels, "/"), nil
+}
+
+// mqttConnection hides the differences between the v3.1.1 and v5 clients
+type mqttConnection interface {
+	subscribe(ctx context.Context, filter string, qos byte) error
+	publish(ctx context.Context, topic string, qos byte, payload []byte) error
+	disconnect(ctx context.Context) error
+}
+
+type mqttSubscription struct {
+	template TopicTemplate
+	handler  Handler[SimpleMessage]
+}
+
+// MQTTClient receives SimpleMessage from, and publishes them to, an MQTT broker.
+// Received messages are acked once their handlers succeed or fail with a Permanent error. MQTT has no nack so a message
+// left unacked by a failed handler is redelivered by the broker when the persistent session reconnects.
+type MQTTClient struct {
+	config MQTTConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu            sync.Mutex
+	conn          mqttConnection
+	subscriptions []mqttSubscription
+}
+
+// NewMQTTClient connects to the broker, the client reconnects and resubscribes by itself until Close
+func NewMQTTClient(ctx context.Context, config MQTTConfig) (*MQTTClient, error) {
+	if config.Version == 0 {
+		config.Version = MQTTv311
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	clientCtx, cancel := context.WithCancel(context.Background())
+	c := &MQTTClient{config: config, ctx: clientCtx, cancel: cancel}
+
+	var conn mqttConnection
+	var err error
+	if config.Version == MQTTv5 {
+		conn, err = newMQTTv5Connection(ctx, clientCtx, config, c.resubscribe, c.receive)
+	} else {
+		conn, err = newMQTTv3Connection(ctx, config, c.resubscribe, c.receive)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+	return c, nil
+}
+
+// Subscribe passes every message on a topic matching template to handler
+func (c *MQTTClient) Subscribe(ctx context.Context, template TopicTemplate, handler Handler[SimpleMessage]) error {
+	c.mu.Lock()
+	c.subscriptions = append(c.subscriptions, mqttSubscription{template: template, handler: handler})
+	conn := c.conn
+	c.mu.Unlock()
+
+	if err := conn.subscribe(ctx, template.Filter(), c.config.QoS); err != nil {
+		return fmt.Errorf("could not subscribe to %s: %w", template.Filter(), err)
+	}
+	return nil
+}
+
+// Bridge forwards every message matching template to publisher with the device attributes, pass WithOrderingKey in
+// opts only if the topic has message ordering enabled
+func (c *MQTTClient) Bridge(ctx context.Context, template TopicTemplate, publisher Publisher, opts ...PublishOption) error {
+	return c.Subscribe(ctx, template, func(ctx context.Context, m SimpleMessage) error {
+		_, err := publisher.Publish(ctx, m, append([]PublishOption{WithBrokerDevice(m.BrokerDevice)}, opts...)...)
+		return err
+	})
+}
+
+// Publish sends the message payload to the template's topic for the message's DeviceUID
+func (c *MQTTClient) Publish(ctx context.Context, template TopicTemplate, m SimpleMessage) error {
+	topic, err := template.Topic(m.DeviceUID)
+	if err != nil {
+		return err
+	}
+	if err = c.conn.publish(ctx, topic, c.config.QoS, m.Payload); err != nil {
+		return fmt.Errorf("could not publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+func (c *MQTTClient) Close(ctx context.Context) error {
+	defer c.cancel()
+	return c.conn.disconnect(ctx)
+}
+
+// resubscribe restores subscriptions after a reconnect, it is a no-op on the first connect
+func (c *MQTTClient) resubscribe() {
+	c.mu.Lock()
+	conn := c.conn
+	subscriptions := append([]mqttSubscription{}, c.subscriptions...)
+	c.mu.Unlock()
+	if conn == nil {
+		return
+	}
+
+	for _, s := range subscriptions {
+		if err := conn.subscribe(c.ctx, s.template.Filter(), c.config.QoS); err != nil {
+			log.Err(err).Str("filter", s.template.Filter()).Msg("could not resubscribe after reconnect")
+		}
+	}
+}
+
+// receive runs the matching handlers, returning the errors worth redelivering the message for
+func (c *MQTTClient) receive(topic string, payload []byte) error {
+	c.mu.Lock()
+	subscriptions := append([]mqttSubscription{}, c.subscriptions...)
+	c.mu.Unlock()
+
+	var errs []error
+	for _, s := range subscriptions {
+		deviceUID, ok := s.template.DeviceUID(topic)
+		if !ok {
+			continue
+		}
+		m := SimpleMessage{
+			BrokerDevice: BrokerDevice{Source: c.config.Source, DeviceUID: deviceUID},
+			Payload:      payload,
+			Time:         time.Now(),
+		}
+		err := s.handler(c.ctx, m)
+		if IsPermanent(err) {
+			log.Err(err).Str("topic", topic).Msg("dropping mqtt message after permanent handler error")
+			continue
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("topic", topic).Msg("mqtt handler failed, message will not be acked")
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type mqttV3Connection struct {
+	client mqtt.Client
+}
+
+func newMQTTv3Connection(ctx context.Context, config MQTTConfig, onConnect func(), receive func(string, []byte) error) (*mqttV3Connection, error) {
+	opts := mqtt.NewClientOptions().
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetProtocolVersion(uint(MQTTv311)).
+		SetCleanSession(config.CleanSession).
+		SetAutoReconnect(true).
+		SetOrderMatters(false).
+		SetAutoAckDisabled(true).
+		SetDefaultPublishHandler(func(_ mqtt.Client, m mqtt.Message) {
+			if receive(m.Topic(), m.Payload()) == nil {
+				m.Ack()
+			}
+		}).
+		SetOnConnectHandler(func(mqtt.Client) { onConnect() })
+	for _, broker := range config.Brokers {
+		opts.AddBroker(broker)
+	}
+	if config.TLS != nil {
+		opts.SetTLSConfig(config.TLS)
+	}
+	if config.KeepAlive > 0 {
+		opts.SetKeepAlive(config.KeepAlive)
+	}
+	if config.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(config.ConnectTimeout)
+	}
+
+	client := mqtt.NewClient(opts)
+	if err := waitToken(ctx, client.Connect()); err != nil {
+		return nil, fmt.Errorf("could not connect to mqtt broker: %w", err)
+	}
+	return &mqttV3Connection{client: client}, nil
+}
+
+func waitToken(ctx context.Context, token mqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *mqttV3Connection) subscribe(ctx context.Context, filter string, qos byte) error {
+	// a nil callback routes messages to the default publish handler
+	return waitToken(ctx, c.client.Subscribe(filter, qos, nil))
+}
+
+func (c *mqttV3Connection) publish(ctx context.Context, topic string, qos byte, payload []byte) error {
+	return waitToken(ctx, c.client.Publish(topic, qos, false, payload))
+}
+
+func (c *mqttV3Connection) disconnect(ctx context.Context) error {
+	quiesce := uint(250)
+	if deadline, ok := ctx.Deadline(); ok {
+		quiesce = uint(max(time.Until(deadline).Milliseconds(), 0))
+	}
+	c.client.Disconnect(quiesce)
+	return nil
+}
+
+type mqttV5Connection struct {
+	cm *autopaho.ConnectionManager
+}
+
+// newMQTTv5Connection connects within ctx, the connection itself lives until clientCtx is cancelled
+func newMQTTv5Connection(ctx, clientCtx context.Context, config MQTTConfig, onConnect func(), receive func(string, []byte) error) (*mqttV5Connection, error) {
+	urls := make([]*url.URL, 0, len(config.Brokers))
+	for _, broker := range config.Brokers {
+		u, err := url.Parse(broker)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mqtt broker url %s: %v", broker, err)
+		}
+		urls = append(urls, u)
+	}
+
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    urls,
+		TlsCfg:                        config.TLS,
+		KeepAlive:                     uint16(config.KeepAlive.Seconds()),
+		CleanStartOnInitialConnection: config.CleanSession,
+		SessionExpiryInterval:         uint32(config.SessionExpiry.Seconds()),
+		ConnectTimeout:                config.ConnectTimeout,
+		ConnectUsername:               config.Username,
+		ConnectPassword:               []byte(config.Password),
+		OnConnectionUp: func(*autopaho.ConnectionManager, *paho.Connack) {
+			onConnect()
+		},
+		OnConnectError: func(err error) {
+			log.Debug().Err(err).Msg("mqtt connection attempt failed")
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID:                   config.ClientID,
+			EnableManualAcknowledgment: true,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(pr paho.PublishReceived) (bool, error) {
+					if err := receive(pr.Packet.Topic, pr.Packet.Payload); err != nil {
+						return true, nil
+					}
+					return true, pr.Client.Ack(pr.Packet)
+				},
+			},
+		},
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = 30
+	}
+
+	cm, err := autopaho.NewConnection(clientCtx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not create mqtt connection: %w", err)
+	}
+	if err = cm.AwaitConnection(ctx); err != nil {
+		return nil, fmt.Errorf("could not connect to mqtt broker: %w", err)
+	}
+	return &mqttV5Connection{cm: cm}, nil
+}
+
+func (c *mqttV5Connection) subscribe(ctx context.Context, filter string, qos byte) error {
+	_, err := c.cm.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: filter, QoS: qos}},
+	})
+	return err
+}
+
+func (c *mqttV5Connection) publish(ctx context.Context, topic string, qos byte, payload []byte) error {
+	_, err := c.cm.Publish(ctx, &paho.Publish{Topic: topic, QoS: qos, Payload: payload})
+	return err
+}
+
+func (c *mqttV5Connection) disconnect(ctx context.Context) error {
+	return c.cm.Disconnect(ctx)
+}
@@ -0,0 +1,151 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestBroker starts an embedded mqtt broker on a free local port
+func newTestBroker(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not find a free port: %v", err)
+	}
+	address := l.Addr().String()
+	_ = l.Close()
+
+	server := mochi.New(&mochi.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err = server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("could not add auth hook: %v", err)
+	}
+	if err = server.AddListener(listeners.NewTCP(listeners.Config{ID: "test", Address: address})); err != nil {
+		t.Fatalf("could not add listener: %v", err)
+	}
+	go func() {
+		_ = server.Serve()
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+	return "tcp://" + address
+}
+
+func TestTopicTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		topic     string
+		wantUID   string
+		wantMatch bool
+		wantErr   bool
+	}{
+		{name: "match", template: "devices/{deviceUID}/up", topic: "devices/abc/up", wantUID: "abc", wantMatch: true},
+		{name: "wildcard match", template: "application/+/device/{deviceUID}/event/up", topic: "application/1/device/abc/event/up", wantUID: "abc", wantMatch: true},
+		{name: "multi level", template: "devices/{deviceUID}/#", topic: "devices/abc/up/raw", wantUID: "abc", wantMatch: true},
+		{name: "wrong literal", template: "devices/{deviceUID}/up", topic: "devices/abc/down"},
+		{name: "too long", template: "devices/{deviceUID}/up", topic: "devices/abc/up/raw"},
+		{name: "too short", template: "devices/{deviceUID}/up", topic: "devices/abc"},
+		{name: "empty uid", template: "devices/{deviceUID}/up", topic: "devices//up"},
+		{name: "no placeholder", template: "devices/+/up", wantErr: true},
+		{name: "early hash", template: "devices/#/{deviceUID}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := NewTopicTemplate(tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTopicTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			uid, ok := template.DeviceUID(tt.topic)
+			if ok != tt.wantMatch || uid != tt.wantUID {
+				t.Errorf("DeviceUID() = %s, %v want %s, %v", uid, ok, tt.wantUID, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestMQTTClient_Bridge(t *testing.T) {
+	broker := newTestBroker(t)
+	template, err := NewTopicTemplate("devices/{deviceUID}/up")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []MQTTVersion{MQTTv311, MQTTv5} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		c, err := NewMQTTClient(ctx, MQTTConfig{
+			Brokers:      []string{broker},
+			ClientID:     "bridge-test",
+			Version:      version,
+			QoS:          1,
+			CleanSession: true,
+			Source:       "mqtt",
+		})
+		if err != nil {
+			t.Fatalf("NewMQTTClient(v%d) error = %v", version, err)
+		}
+
+		publisher := NewMemoryPublisher()
+		if err = c.Bridge(ctx, template, publisher); err != nil {
+			t.Fatalf("Bridge(v%d) error = %v", version, err)
+		}
+		m := SimpleMessage{BrokerDevice: BrokerDevice{DeviceUID: "device-1"}, Payload: []byte("reading")}
+		if err = c.Publish(ctx, template, m); err != nil {
+			t.Fatalf("Publish(v%d) error = %v", version, err)
+		}
+
+		for len(publisher.Messages()) == 0 && ctx.Err() == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+		messages := publisher.Messages()
+		if len(messages) != 1 {
+			t.Fatalf("Bridge(v%d) published %d messages, want 1", version, len(messages))
+		}
+		if messages[0].Attributes[AttributeDeviceUID] != "device-1" || messages[0].Attributes[AttributeSource] != "mqtt" {
+			t.Errorf("Bridge(v%d) attributes = %v", version, messages[0].Attributes)
+		}
+
+		_ = c.Close(ctx)
+		cancel()
+	}
+}
+
+func TestMQTTClient_receive(t *testing.T) {
+	template, err := NewTopicTemplate("devices/{deviceUID}/up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		handlerErr error
+		wantAck    bool
+	}{
+		{name: "handled", wantAck: true},
+		{name: "permanent", handlerErr: Permanent(fmt.Errorf("bad payload")), wantAck: true},
+		{name: "transient", handlerErr: fmt.Errorf("publish failed")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &MQTTClient{ctx: context.Background(), subscriptions: []mqttSubscription{{
+				template: template,
+				handler: func(context.Context, SimpleMessage) error {
+					return tt.handlerErr
+				},
+			}}}
+			if err := c.receive("devices/abc/up", nil); (err == nil) != tt.wantAck {
+				t.Errorf("receive() error = %v, want ack %v", err, tt.wantAck)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownProcessor is returned when DeviceMeta.Processors names a processor that isn't registered
+var ErrUnknownProcessor = errors.New("unknown processor")
+
+// Stage is a built processor, returning a nil message drops it from the pipeline
+type Stage func(ctx context.Context, m EnrichedMessage) (*EnrichedMessage, error)
+
+// Validator is implemented by processor configs that check themselves after decoding
+type Validator interface {
+	Validate() error
+}
+
+type processorEntry struct {
+	name  string
+	build func(raw interface{}) (Stage, error)
+}
+
+// maxBuiltPipelines bounds the pipelines Handler keeps, the cache is emptied when it fills
+const maxBuiltPipelines = 1024
+
+type builtPipeline struct {
+	pipeline *Pipeline
+	err      error
+}
+
+// ProcessorRegistry maps the names used in DeviceMeta.Processors to typed configs and the stages built from them.
+// Stages run in registration order whatever order the device's map holds them in.
+type ProcessorRegistry struct {
+	mu         sync.RWMutex
+	processors []processorEntry
+	// built caches Handler's pipelines by the json of the device's Processors
+	builtMu sync.Mutex
+	built   map[string]builtPipeline
+	// generation moves on with each registration so a pipeline built before it isn't cached
+	generation int
+}
+
+func NewProcessorRegistry() *ProcessorRegistry {
+	return &ProcessorRegistry{}
+}
+
+// RegisterProcessor adds a processor whose config C is decoded from the device's Processors entry, unknown config
+// fields are refused and C is validated if it is a Validator
+func RegisterProcessor[C any](r *ProcessorRegistry, name string, build func(config C) (Stage, error)) error {
+	if name == "" || build == nil {
+		return fmt.Errorf("processor needs a name and build func")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.processors {
+		if p.name == name {
+			return fmt.Errorf("processor %s is already registered", name)
+		}
+	}
+	r.resetBuilt()
+	r.processors = append(r.processors, processorEntry{
+		name: name,
+		build: func(raw interface{}) (Stage, error) {
+			config, err := decodeProcessorConfig[C](name, raw)
+			if err != nil {
+				return nil, err
+			}
+			return build(config)
+		},
+	})
+	return nil
+}
+
+// Names lists the registered processors in pipeline order
+func (r *ProcessorRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, len(r.processors))
+	for i, p := range r.processors {
+		names[i] = p.name
+	}
+	return names
+}
+
+// Pipeline builds the stages named in processors, all unknown names and bad configs are reported together
+func (r *ProcessorRegistry) Pipeline(processors *lib.Processor) (*Pipeline, error) {
+	pipeline := &Pipeline{}
+	if processors == nil {
+		return pipeline, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	known := make(map[string]bool, len(r.processors))
+	var errs []error
+	for _, p := range r.processors {
+		known[p.name] = true
+		raw, ok := (*processors)[p.name]
+		if !ok {
+			continue
+		}
+		stage, err := p.build(raw)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pipeline.names = append(pipeline.names, p.name)
+		pipeline.stages = append(pipeline.stages, stage)
+	}
+	var unknown []string
+	for name := range *processors {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrUnknownProcessor, name))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return pipeline, nil
+}
+
+// DecodeProcessorConfig reads a single processor's config from a device's Processors, reporting whether it was set
+func DecodeProcessorConfig[C any](processors *lib.Processor, name string) (C, bool, error) {
+	var config C
+	if processors == nil {
+		return config, false, nil
+	}
+	raw, ok := (*processors)[name]
+	if !ok {
+		return config, false, nil
+	}
+	config, err := decodeProcessorConfig[C](name, raw)
+	return config, true, err
+}
+
+// decodeProcessorConfig round trips raw through json so map values from firestore or json and typed values decode alike
+func decodeProcessorConfig[C any](name string, raw interface{}) (C, error) {
+	var config C
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return config, fmt.Errorf("processor %s config: %w", name, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("processor %s config: %w", name, err)
+	}
+	if v, ok := any(&config).(Validator); ok {
+		if err = v.Validate(); err != nil {
+			return config, fmt.Errorf("processor %s config: %w", name, err)
+		}
+	}
+	return config, nil
+}
+
+// Pipeline is a device's chain of processor stages
+type Pipeline struct {
+	names  []string
+	stages []Stage
+}
+
+// Names lists the pipeline's stages in the order they run
+func (p *Pipeline) Names() []string {
+	return p.names
+}
+
+// Process runs m through each stage, a nil result means a stage dropped the message
+func (p *Pipeline) Process(ctx context.Context, m EnrichedMessage) (*EnrichedMessage, error) {
+	current := &m
+	for i, stage := range p.stages {
+		next, err := stage(ctx, *current)
+		if err != nil {
+			return nil, fmt.Errorf("processor %s: %w", p.names[i], err)
+		}
+		if next == nil {
+			return nil, nil
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// Handler runs each message through the pipeline built from its device's Processors before next.
+// Pipelines are built once per distinct Processors config and shared by the devices using it, so stages shouldn't
+// keep per device state. A device whose Processors can't be built is a Permanent error as redelivery won't fix it.
+func (r *ProcessorRegistry) Handler(next Handler[EnrichedMessage]) Handler[EnrichedMessage] {
+	return func(ctx context.Context, m EnrichedMessage) error {
+		var processors *lib.Processor
+		if m.DeviceMeta != nil {
+			processors = m.Processors
+		}
+		pipeline, err := r.cachedPipeline(processors)
+		if err != nil {
+			return Permanent(fmt.Errorf("device %s processors: %w", m.DeviceUID, err))
+		}
+		out, err := pipeline.Process(ctx, m)
+		if err != nil || out == nil {
+			return err
+		}
+		return next(ctx, *out)
+	}
+}
+
+// cachedPipeline builds processors' pipeline on first use, json sorts map keys so equal configs share a key
+func (r *ProcessorRegistry) cachedPipeline(processors *lib.Processor) (*Pipeline, error) {
+	if processors == nil {
+		return r.Pipeline(nil)
+	}
+	b, err := json.Marshal(processors)
+	if err != nil {
+		return r.Pipeline(processors)
+	}
+	key := string(b)
+
+	r.builtMu.Lock()
+	built, ok := r.built[key]
+	generation := r.generation
+	r.builtMu.Unlock()
+	if ok {
+		return built.pipeline, built.err
+	}
+
+	built.pipeline, built.err = r.Pipeline(processors)
+	r.builtMu.Lock()
+	defer r.builtMu.Unlock()
+	if generation != r.generation {
+		return built.pipeline, built.err
+	}
+	if r.built == nil || len(r.built) >= maxBuiltPipelines {
+		r.built = make(map[string]builtPipeline)
+	}
+	r.built[key] = built
+	return built.pipeline, built.err
+}
+
+func (r *ProcessorRegistry) resetBuilt() {
+	r.builtMu.Lock()
+	defer r.builtMu.Unlock()
+	r.built = nil
+	r.generation++
+}
@@ -0,0 +1,174 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/safecility/go/lib"
+	"testing"
+)
+
+type scaleConfig struct {
+	Factor int `json:"factor"`
+}
+
+func (c *scaleConfig) Validate() error {
+	if c.Factor == 0 {
+		return fmt.Errorf("factor must not be 0")
+	}
+	return nil
+}
+
+type thresholdConfig struct {
+	Min byte `json:"min"`
+}
+
+func newTestProcessorRegistry(t *testing.T) *ProcessorRegistry {
+	r := NewProcessorRegistry()
+	err := RegisterProcessor(r, "scale", func(c scaleConfig) (Stage, error) {
+		return func(ctx context.Context, m EnrichedMessage) (*EnrichedMessage, error) {
+			payload := make([]byte, len(m.Payload))
+			for i, b := range m.Payload {
+				payload[i] = b * byte(c.Factor)
+			}
+			m.Payload = payload
+			return &m, nil
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RegisterProcessor(r, "threshold", func(c thresholdConfig) (Stage, error) {
+		return func(ctx context.Context, m EnrichedMessage) (*EnrichedMessage, error) {
+			if len(m.Payload) == 0 || m.Payload[0] < c.Min {
+				return nil, nil
+			}
+			return &m, nil
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestProcessorRegistry_Handler(t *testing.T) {
+	r := newTestProcessorRegistry(t)
+
+	tests := []struct {
+		name          string
+		processors    *lib.Processor
+		payload       []byte
+		want          []byte
+		wantErr       error
+		wantPermanent bool
+	}{
+		{name: "no processors", payload: []byte{1}, want: []byte{1}},
+		{
+			name:       "chained in registration order",
+			processors: &lib.Processor{"threshold": map[string]interface{}{"min": 4}, "scale": map[string]interface{}{"factor": 2}},
+			payload:    []byte{2},
+			want:       []byte{4},
+		},
+		{
+			name:       "dropped",
+			processors: &lib.Processor{"threshold": thresholdConfig{Min: 5}},
+			payload:    []byte{2},
+		},
+		{
+			name:          "unknown processor",
+			processors:    &lib.Processor{"scale": map[string]interface{}{"factor": 2}, "smooth": true},
+			payload:       []byte{2},
+			wantErr:       ErrUnknownProcessor,
+			wantPermanent: true,
+		},
+		{
+			name:          "invalid config",
+			processors:    &lib.Processor{"scale": map[string]interface{}{"factor": 0}},
+			payload:       []byte{2},
+			wantPermanent: true,
+		},
+		{
+			name:          "unknown config field",
+			processors:    &lib.Processor{"scale": map[string]interface{}{"factor": 2, "offset": 1}},
+			payload:       []byte{2},
+			wantPermanent: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []byte
+			handler := r.Handler(func(ctx context.Context, m EnrichedMessage) error {
+				got = m.Payload
+				return nil
+			})
+			m := EnrichedMessage{
+				Device:  lib.Device{DeviceUID: "a", DeviceMeta: &lib.DeviceMeta{Processors: tt.processors}},
+				Payload: tt.payload,
+			}
+			err := handler(context.Background(), m)
+			if IsPermanent(err) != tt.wantPermanent {
+				t.Fatalf("Handler() error = %v, want permanent %v", err, tt.wantPermanent)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Handler() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != string(tt.want) {
+				t.Errorf("Handler() payload = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessorRegistry_HandlerCachesPipelines(t *testing.T) {
+	r := NewProcessorRegistry()
+	builds := 0
+	err := RegisterProcessor(r, "scale", func(c scaleConfig) (Stage, error) {
+		builds++
+		return func(ctx context.Context, m EnrichedMessage) (*EnrichedMessage, error) {
+			return &m, nil
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := r.Handler(func(ctx context.Context, m EnrichedMessage) error { return nil })
+	for i, factor := range []int{2, 2, 3, 2} {
+		m := EnrichedMessage{Device: lib.Device{DeviceUID: fmt.Sprintf("d%d", i), DeviceMeta: &lib.DeviceMeta{
+			Processors: &lib.Processor{"scale": map[string]interface{}{"factor": factor}},
+		}}}
+		if err = handler(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if builds != 2 {
+		t.Errorf("built %d pipelines for 2 distinct configs", builds)
+	}
+
+	if err = RegisterProcessor(r, "threshold", func(c thresholdConfig) (Stage, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+	m := EnrichedMessage{Device: lib.Device{DeviceUID: "d0", DeviceMeta: &lib.DeviceMeta{
+		Processors: &lib.Processor{"scale": map[string]interface{}{"factor": 2}},
+	}}}
+	if err = handler(context.Background(), m); err != nil || builds != 3 {
+		t.Errorf("registering a processor should rebuild, builds = %d, error = %v", builds, err)
+	}
+}
+
+func TestDecodeProcessorConfig(t *testing.T) {
+	processors := &lib.Processor{"scale": map[string]interface{}{"factor": 3}}
+	c, ok, err := DecodeProcessorConfig[scaleConfig](processors, "scale")
+	if err != nil || !ok || c.Factor != 3 {
+		t.Errorf("DecodeProcessorConfig() = %v, %v, %v", c, ok, err)
+	}
+	if _, ok, err = DecodeProcessorConfig[scaleConfig](processors, "threshold"); ok || err != nil {
+		t.Errorf("DecodeProcessorConfig() of a missing processor = %v, %v", ok, err)
+	}
+	if err = RegisterProcessor(NewProcessorRegistry(), "", func(c scaleConfig) (Stage, error) { return nil, nil }); err == nil {
+		t.Errorf("RegisterProcessor() should refuse an empty name")
+	}
+	if names := newTestProcessorRegistry(t).Names(); fmt.Sprint(names) != "[scale threshold]" {
+		t.Errorf("Names() = %v", names)
+	}
+}
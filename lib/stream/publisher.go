@@ -0,0 +1,159 @@
+package stream
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"sync"
+	"time"
+)
+
+// Publisher hides the broker behind the publish calls our services actually make.
+// Services should depend on Publisher rather than a concrete *pubsub.Topic so they can be tested with a MemoryPublisher
+type Publisher interface {
+	// Publish marshals m to json and publishes it, returning the broker's message id
+	Publish(ctx context.Context, m interface{}, opts ...PublishOption) (*string, error)
+	// PublishProto marshals m with the given encoding and publishes it, returning the broker's message id
+	PublishProto(ctx context.Context, m proto.Message, encoding pubsub.SchemaEncoding, opts ...PublishOption) (*string, error)
+	// Flush blocks until all outstanding messages have been sent
+	Flush()
+	// Close flushes and releases any resources held by the Publisher
+	Close() error
+}
+
+// PubSubPublisher is the google pubsub Publisher, it wraps PublishToTopic and PublishProtoToTopic
+type PubSubPublisher struct {
+	topic *pubsub.Topic
+}
+
+func NewPubSubPublisher(topic *pubsub.Topic) *PubSubPublisher {
+	return &PubSubPublisher{topic: topic}
+}
+
+// Topic gives access to the underlying topic for settings not covered by Publisher
+func (p *PubSubPublisher) Topic() *pubsub.Topic {
+	return p.topic
+}
+
+func (p *PubSubPublisher) Publish(ctx context.Context, m interface{}, opts ...PublishOption) (*string, error) {
+	return PublishToTopicContext(ctx, m, p.topic, opts...)
+}
+
+func (p *PubSubPublisher) PublishProto(ctx context.Context, m proto.Message, encoding pubsub.SchemaEncoding, opts ...PublishOption) (*string, error) {
+	return PublishProtoToTopicContext(ctx, m, encoding, p.topic, opts...)
+}
+
+func (p *PubSubPublisher) Flush() {
+	if p.topic != nil {
+		p.topic.Flush()
+	}
+}
+
+// Close stops the topic's publishing goroutines - the topic itself belongs to its client and is not deleted
+func (p *PubSubPublisher) Close() error {
+	if p.topic != nil {
+		p.topic.Stop()
+	}
+	return nil
+}
+
+// ErrOrderingNotEnabled is returned for a message with an OrderingKey on a topic without message ordering, as pubsub does
+var ErrOrderingNotEnabled = errors.New("message ordering not enabled")
+
+// PublishedMessage is the record a MemoryPublisher keeps of each message
+type PublishedMessage struct {
+	ID          string
+	Data        []byte
+	Attributes  map[string]string
+	OrderingKey string
+	PublishTime time.Time
+}
+
+// MemoryPublisher records published messages in memory so tests can assert on what a service published
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []PublishedMessage
+	closed   bool
+	// Err, if set, is returned from every publish call
+	Err error
+	// EnableMessageOrdering accepts messages with an OrderingKey like the pubsub topic setting of the same name
+	EnableMessageOrdering bool
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, m interface{}, opts ...PublishOption) (*string, error) {
+	data, err := marshalJSON(m)
+	if err != nil {
+		return nil, err
+	}
+	return p.record(ctx, data, opts)
+}
+
+func (p *MemoryPublisher) PublishProto(ctx context.Context, m proto.Message, encoding pubsub.SchemaEncoding, opts ...PublishOption) (*string, error) {
+	data, err := marshalProto(m, encoding)
+	if err != nil {
+		return nil, err
+	}
+	return p.record(ctx, data, opts)
+}
+
+func (p *MemoryPublisher) record(ctx context.Context, data []byte, opts []PublishOption) (*string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, fmt.Errorf("publisher is closed")
+	}
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	o := newPublishOptions(opts)
+	if o.OrderingKey != "" && !p.EnableMessageOrdering {
+		return nil, fmt.Errorf("%w: ordering key %s", ErrOrderingNotEnabled, o.OrderingKey)
+	}
+	id := fmt.Sprintf("%d", len(p.messages)+1)
+	p.messages = append(p.messages, PublishedMessage{
+		ID:          id,
+		Data:        data,
+		Attributes:  o.Attributes,
+		OrderingKey: o.OrderingKey,
+		PublishTime: time.Now(),
+	})
+	return &id, nil
+}
+
+// Messages returns a copy of everything published so far
+func (p *MemoryPublisher) Messages() []PublishedMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	messages := make([]PublishedMessage, len(p.messages))
+	copy(messages, p.messages)
+	return messages
+}
+
+// Reset forgets all recorded messages
+func (p *MemoryPublisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.messages = nil
+}
+
+func (p *MemoryPublisher) Flush() {}
+
+func (p *MemoryPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	return nil
+}
@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"encoding/json"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+	"time"
+)
+
+func TestMemoryPublisher_Publish(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       interface{}
+		wantErr bool
+	}{
+		{
+			name: "simple message",
+			m: SimpleMessage{
+				BrokerDevice: BrokerDevice{Source: "test", DeviceUID: "device-1"},
+				Payload:      []byte{0x01, 0x02},
+				Time:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "unmarshallable",
+			m:       make(chan int),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewMemoryPublisher()
+			var publisher Publisher = p
+			got, err := publisher.Publish(context.Background(), tt.m)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Publish() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if len(p.Messages()) != 0 {
+					t.Errorf("Publish() recorded a failed message")
+				}
+				return
+			}
+			messages := p.Messages()
+			if len(messages) != 1 || messages[0].ID != *got {
+				t.Fatalf("Publish() recorded %v, want one message with id %s", messages, *got)
+			}
+			want, _ := json.Marshal(tt.m)
+			if string(messages[0].Data) != string(want) {
+				t.Errorf("Publish() data = %s, want %s", messages[0].Data, want)
+			}
+		})
+	}
+}
+
+func TestMemoryPublisher_PublishProto(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding pubsub.SchemaEncoding
+		wantErr  bool
+	}{
+		{name: "binary", encoding: pubsub.EncodingBinary},
+		{name: "json", encoding: pubsub.EncodingJSON},
+		{name: "unspecified", encoding: pubsub.EncodingUnspecified, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewMemoryPublisher()
+			m := wrapperspb.String("reading")
+			_, err := p.PublishProto(context.Background(), m, tt.encoding)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PublishProto() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.encoding == pubsub.EncodingBinary {
+				got := &wrapperspb.StringValue{}
+				if err = proto.Unmarshal(p.Messages()[0].Data, got); err != nil || got.Value != m.Value {
+					t.Errorf("PublishProto() recorded %v, err %v", got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryPublisher_Close(t *testing.T) {
+	p := NewMemoryPublisher()
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := p.Publish(context.Background(), "late"); err == nil {
+		t.Errorf("Publish() after Close() should fail")
+	}
+}
+
+func TestMemoryPublisher_PublishCancelled(t *testing.T) {
+	p := NewMemoryPublisher()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Publish(ctx, "cancelled"); err == nil {
+		t.Errorf("Publish() with cancelled context should fail")
+	}
+	if len(p.Messages()) != 0 {
+		t.Errorf("Publish() with cancelled context recorded a message")
+	}
+}
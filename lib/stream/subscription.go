@@ -0,0 +1,280 @@
+package stream
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"reflect"
+	"time"
+)
+
+// limits enforced by pubsub, checked up front so a bad config fails before any resources are created
+const (
+	minAckDeadline          = 10 * time.Second
+	maxAckDeadline          = 600 * time.Second
+	minRetentionDuration    = 10 * time.Minute
+	maxRetentionDuration    = 7 * 24 * time.Hour
+	defaultRetention        = 7 * 24 * time.Hour
+	minExpiration           = 24 * time.Hour
+	minMaxDeliveryAttempts  = 5
+	maxMaxDeliveryAttempts  = 100
+	maxRetryBackoff         = 600 * time.Second
+	defaultDeliveryAttempts = 5
+)
+
+// SubscriptionBuilder builds on GetDefaultSubscriptionConfig with the delivery settings our services need.
+// Setters can be chained and any problems are reported together by Validate, Config or CreateOrUpdate.
+type SubscriptionBuilder struct {
+	client *pubsub.Client
+	id     string
+	config pubsub.SubscriptionConfig
+
+	deadLetterTopicID string
+}
+
+func NewSubscriptionBuilder(client *pubsub.Client, id string, topic *pubsub.Topic) *SubscriptionBuilder {
+	return &SubscriptionBuilder{
+		client: client,
+		id:     id,
+		config: GetDefaultSubscriptionConfig(topic, 0),
+	}
+}
+
+func (b *SubscriptionBuilder) AckDeadline(d time.Duration) *SubscriptionBuilder {
+	b.config.AckDeadline = d
+	return b
+}
+
+// Retention keeps unacked messages for d, acked messages too if retainAcked is set
+func (b *SubscriptionBuilder) Retention(d time.Duration, retainAcked bool) *SubscriptionBuilder {
+	b.config.RetentionDuration = d
+	b.config.RetainAckedMessages = retainAcked
+	return b
+}
+
+// DeadLetter forwards messages to topicID after maxDeliveryAttempts - the topic is created if it does not exist.
+// A maxDeliveryAttempts of 0 uses the pubsub default of 5.
+func (b *SubscriptionBuilder) DeadLetter(topicID string, maxDeliveryAttempts int) *SubscriptionBuilder {
+	if maxDeliveryAttempts == 0 {
+		maxDeliveryAttempts = defaultDeliveryAttempts
+	}
+	b.deadLetterTopicID = topicID
+	b.config.DeadLetterPolicy = &pubsub.DeadLetterPolicy{MaxDeliveryAttempts: maxDeliveryAttempts}
+	return b
+}
+
+// RetryBackoff replaces immediate redelivery of nacked messages with exponential backoff between min and max
+func (b *SubscriptionBuilder) RetryBackoff(minBackoff, maxBackoff time.Duration) *SubscriptionBuilder {
+	b.config.RetryPolicy = &pubsub.RetryPolicy{MinimumBackoff: minBackoff, MaximumBackoff: maxBackoff}
+	return b
+}
+
+// Filter only delivers messages whose attributes match filter e.g. `attributes.deviceType = "power"`
+func (b *SubscriptionBuilder) Filter(filter string) *SubscriptionBuilder {
+	b.config.Filter = filter
+	return b
+}
+
+// Ordered delivers messages with the same ordering key in order
+func (b *SubscriptionBuilder) Ordered() *SubscriptionBuilder {
+	b.config.EnableMessageOrdering = true
+	return b
+}
+
+func (b *SubscriptionBuilder) ExactlyOnce() *SubscriptionBuilder {
+	b.config.EnableExactlyOnceDelivery = true
+	return b
+}
+
+// Expiration deletes the subscription after ttl without subscriber activity, a ttl of 0 never expires
+func (b *SubscriptionBuilder) Expiration(ttl time.Duration) *SubscriptionBuilder {
+	b.config.ExpirationPolicy = ttl
+	return b
+}
+
+func (b *SubscriptionBuilder) Labels(labels map[string]string) *SubscriptionBuilder {
+	b.config.Labels = labels
+	return b
+}
+
+// Validate reports every setting pubsub would reject and any incompatible combinations
+func (b *SubscriptionBuilder) Validate() error {
+	var errs []error
+	c := b.config
+
+	if b.id == "" {
+		errs = append(errs, fmt.Errorf("subscription id is required"))
+	}
+	if c.Topic == nil {
+		errs = append(errs, fmt.Errorf("subscription topic is required"))
+	}
+	if c.AckDeadline != 0 && (c.AckDeadline < minAckDeadline || c.AckDeadline > maxAckDeadline) {
+		errs = append(errs, fmt.Errorf("ack deadline %v must be between %v and %v", c.AckDeadline, minAckDeadline, maxAckDeadline))
+	}
+	if c.RetentionDuration != 0 && (c.RetentionDuration < minRetentionDuration || c.RetentionDuration > maxRetentionDuration) {
+		errs = append(errs, fmt.Errorf("retention %v must be between %v and %v", c.RetentionDuration, minRetentionDuration, maxRetentionDuration))
+	}
+	if ttl, ok := c.ExpirationPolicy.(time.Duration); ok {
+		// the server retains for its default when no retention is set
+		retention := c.RetentionDuration
+		if retention == 0 {
+			retention = defaultRetention
+		}
+		if ttl != 0 && ttl < minExpiration {
+			errs = append(errs, fmt.Errorf("expiration %v must be at least %v", ttl, minExpiration))
+		}
+		if ttl != 0 && ttl < retention {
+			errs = append(errs, fmt.Errorf("expiration %v is shorter than retention %v", ttl, retention))
+		}
+	}
+	if c.DeadLetterPolicy != nil {
+		if b.deadLetterTopicID == "" {
+			errs = append(errs, fmt.Errorf("dead letter topic is required"))
+		}
+		if c.Topic != nil && b.deadLetterTopicID == c.Topic.ID() {
+			errs = append(errs, fmt.Errorf("dead letter topic must differ from the subscription topic"))
+		}
+		attempts := c.DeadLetterPolicy.MaxDeliveryAttempts
+		if attempts < minMaxDeliveryAttempts || attempts > maxMaxDeliveryAttempts {
+			errs = append(errs, fmt.Errorf("max delivery attempts %d must be between %d and %d", attempts, minMaxDeliveryAttempts, maxMaxDeliveryAttempts))
+		}
+	}
+	if c.RetryPolicy != nil {
+		minBackoff, _ := c.RetryPolicy.MinimumBackoff.(time.Duration)
+		maxBackoff, _ := c.RetryPolicy.MaximumBackoff.(time.Duration)
+		if minBackoff < 0 || maxBackoff > maxRetryBackoff || minBackoff > maxBackoff {
+			errs = append(errs, fmt.Errorf("retry backoff %v-%v must satisfy 0 <= min <= max <= %v", minBackoff, maxBackoff, maxRetryBackoff))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Config validates and returns the subscription config - the dead letter topic name is only filled by CreateOrUpdate
+func (b *SubscriptionBuilder) Config() (pubsub.SubscriptionConfig, error) {
+	if err := b.Validate(); err != nil {
+		return pubsub.SubscriptionConfig{}, err
+	}
+	return b.config, nil
+}
+
+// CreateOrUpdate creates the subscription, or brings an existing one in line with the builder.
+// Filter and ordering cannot be changed on an existing subscription so a mismatch is an error rather than an update.
+func (b *SubscriptionBuilder) CreateOrUpdate(ctx context.Context) (*pubsub.Subscription, error) {
+	config, err := b.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.DeadLetterPolicy != nil {
+		deadLetterTopic, err := b.deadLetterTopic(ctx)
+		if err != nil {
+			return nil, err
+		}
+		config.DeadLetterPolicy = &pubsub.DeadLetterPolicy{
+			DeadLetterTopic:     deadLetterTopic.String(),
+			MaxDeliveryAttempts: config.DeadLetterPolicy.MaxDeliveryAttempts,
+		}
+	}
+
+	sub := b.client.Subscription(b.id)
+	exists, err := sub.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not check subscription %s: %w", b.id, err)
+	}
+	if !exists {
+		sub, err = b.client.CreateSubscription(ctx, b.id, config)
+		if err != nil {
+			return nil, fmt.Errorf("could not create subscription %s: %w", b.id, err)
+		}
+		log.Info().Str("subscription", sub.ID()).Msg("created subscription")
+		return sub, nil
+	}
+
+	current, err := sub.Config(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get subscription %s config: %w", b.id, err)
+	}
+	if current.Filter != config.Filter {
+		return nil, fmt.Errorf("subscription %s filter %q cannot be changed to %q", b.id, current.Filter, config.Filter)
+	}
+	if current.EnableMessageOrdering != config.EnableMessageOrdering {
+		return nil, fmt.Errorf("subscription %s message ordering cannot be changed", b.id)
+	}
+
+	update, changed := configUpdate(current, config)
+	if !changed {
+		return sub, nil
+	}
+	if _, err = sub.Update(ctx, update); err != nil {
+		return nil, fmt.Errorf("could not update subscription %s: %w", b.id, err)
+	}
+	log.Info().Str("subscription", sub.ID()).Msg("updated subscription")
+	return sub, nil
+}
+
+func (b *SubscriptionBuilder) deadLetterTopic(ctx context.Context) (*pubsub.Topic, error) {
+	topic := b.client.Topic(b.deadLetterTopicID)
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not check dead letter topic %s: %w", b.deadLetterTopicID, err)
+	}
+	if exists {
+		return topic, nil
+	}
+	topic, err = b.client.CreateTopic(ctx, b.deadLetterTopicID)
+	if err != nil {
+		return nil, fmt.Errorf("could not create dead letter topic %s: %w", b.deadLetterTopicID, err)
+	}
+	log.Info().Str("topic", topic.ID()).Msg("created dead letter topic")
+	return topic, nil
+}
+
+// configUpdate gives the update needed to take current to want, reporting whether anything differs
+func configUpdate(current, want pubsub.SubscriptionConfig) (pubsub.SubscriptionConfigToUpdate, bool) {
+	var update pubsub.SubscriptionConfigToUpdate
+	changed := false
+
+	if want.AckDeadline != 0 && current.AckDeadline != want.AckDeadline {
+		update.AckDeadline = want.AckDeadline
+		changed = true
+	}
+	if want.RetentionDuration != 0 && current.RetentionDuration != want.RetentionDuration {
+		update.RetentionDuration = want.RetentionDuration
+		changed = true
+	}
+	if current.RetainAckedMessages != want.RetainAckedMessages {
+		update.RetainAckedMessages = want.RetainAckedMessages
+		changed = true
+	}
+	if want.ExpirationPolicy != nil && !reflect.DeepEqual(current.ExpirationPolicy, want.ExpirationPolicy) {
+		update.ExpirationPolicy = want.ExpirationPolicy
+		changed = true
+	}
+	if !reflect.DeepEqual(current.DeadLetterPolicy, want.DeadLetterPolicy) {
+		update.DeadLetterPolicy = want.DeadLetterPolicy
+		if update.DeadLetterPolicy == nil {
+			// an empty policy clears the dead letter topic
+			update.DeadLetterPolicy = &pubsub.DeadLetterPolicy{}
+		}
+		changed = true
+	}
+	if !reflect.DeepEqual(current.RetryPolicy, want.RetryPolicy) {
+		update.RetryPolicy = want.RetryPolicy
+		if update.RetryPolicy == nil {
+			update.RetryPolicy = &pubsub.RetryPolicy{}
+		}
+		changed = true
+	}
+	if want.Labels != nil && !reflect.DeepEqual(current.Labels, want.Labels) {
+		update.Labels = want.Labels
+		changed = true
+	}
+	if current.EnableExactlyOnceDelivery != want.EnableExactlyOnceDelivery {
+		update.EnableExactlyOnceDelivery = want.EnableExactlyOnceDelivery
+		changed = true
+	}
+	return update, changed
+}
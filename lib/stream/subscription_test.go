@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionBuilder_Validate(t *testing.T) {
+	client, _ := newTestClient(t)
+	topic, err := client.CreateTopic(context.Background(), "readings")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		builder *SubscriptionBuilder
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			builder: NewSubscriptionBuilder(client, "readings-sub", topic),
+		},
+		{
+			name: "full",
+			builder: NewSubscriptionBuilder(client, "readings-sub", topic).
+				AckDeadline(30*time.Second).
+				Retention(24*time.Hour, false).
+				DeadLetter("readings-dead", 10).
+				RetryBackoff(10*time.Second, 5*time.Minute).
+				Filter(`attributes.deviceType = "power"`).
+				Ordered().
+				ExactlyOnce().
+				Expiration(0),
+		},
+		{
+			name:    "no topic",
+			builder: NewSubscriptionBuilder(client, "readings-sub", nil),
+			wantErr: true,
+		},
+		{
+			name:    "dead letter to own topic",
+			builder: NewSubscriptionBuilder(client, "readings-sub", topic).DeadLetter("readings", 5),
+			wantErr: true,
+		},
+		{
+			name:    "too many delivery attempts",
+			builder: NewSubscriptionBuilder(client, "readings-sub", topic).DeadLetter("readings-dead", 101),
+			wantErr: true,
+		},
+		{
+			name:    "inverted backoff",
+			builder: NewSubscriptionBuilder(client, "readings-sub", topic).RetryBackoff(time.Minute, time.Second),
+			wantErr: true,
+		},
+		{
+			name:    "expires before retention",
+			builder: NewSubscriptionBuilder(client, "readings-sub", topic).Retention(7*24*time.Hour, true).Expiration(48 * time.Hour),
+			wantErr: true,
+		},
+		{
+			name:    "expires before default retention",
+			builder: NewSubscriptionBuilder(client, "readings-sub", topic).Expiration(48 * time.Hour),
+			wantErr: true,
+		},
+		{
+			name:    "expires after retention",
+			builder: NewSubscriptionBuilder(client, "readings-sub", topic).Retention(24*time.Hour, false).Expiration(48 * time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.builder.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscriptionBuilder_CreateOrUpdate(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	topic, err := client.CreateTopic(ctx, "readings")
+	if err != nil {
+		t.Fatalf("could not create topic: %v", err)
+	}
+
+	sub, err := NewSubscriptionBuilder(client, "readings-sub", topic).
+		DeadLetter("readings-dead", 5).
+		Filter(`attributes.source = "ttn"`).
+		CreateOrUpdate(ctx)
+	if err != nil {
+		t.Fatalf("CreateOrUpdate() create error = %v", err)
+	}
+	if exists, _ := client.Topic("readings-dead").Exists(ctx); !exists {
+		t.Errorf("CreateOrUpdate() did not create the dead letter topic")
+	}
+
+	_, err = NewSubscriptionBuilder(client, "readings-sub", topic).
+		DeadLetter("readings-dead", 20).
+		Filter(`attributes.source = "ttn"`).
+		CreateOrUpdate(ctx)
+	if err != nil {
+		t.Fatalf("CreateOrUpdate() update error = %v", err)
+	}
+	config, err := sub.Config(ctx)
+	if err != nil {
+		t.Fatalf("could not get config: %v", err)
+	}
+	if config.DeadLetterPolicy == nil || config.DeadLetterPolicy.MaxDeliveryAttempts != 20 {
+		t.Errorf("CreateOrUpdate() dead letter policy = %+v, want 20 attempts", config.DeadLetterPolicy)
+	}
+
+	_, err = NewSubscriptionBuilder(client, "readings-sub", topic).
+		Filter(`attributes.source = "chirpstack"`).
+		CreateOrUpdate(ctx)
+	if err == nil {
+		t.Errorf("CreateOrUpdate() should refuse to change the filter")
+	}
+}
@@ -0,0 +1,119 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ttsUplinkEvent is the part of a The Things Stack v3 uplink webhook/MQTT message we use
+type ttsUplinkEvent struct {
+	EndDeviceIDs struct {
+		DeviceID       string `json:"device_id"`
+		DevEUI         string `json:"dev_eui"`
+		DevAddr        string `json:"dev_addr"`
+		ApplicationIDs struct {
+			ApplicationID string `json:"application_id"`
+		} `json:"application_ids"`
+	} `json:"end_device_ids"`
+	ReceivedAt    time.Time `json:"received_at"`
+	UplinkMessage *struct {
+		FPort      uint32    `json:"f_port"`
+		FCnt       uint32    `json:"f_cnt"`
+		FRMPayload []byte    `json:"frm_payload"`
+		ReceivedAt time.Time `json:"received_at"`
+		RxMetadata []struct {
+			GatewayIDs struct {
+				GatewayID string `json:"gateway_id"`
+			} `json:"gateway_ids"`
+			RSSI float64 `json:"rssi"`
+			SNR  float64 `json:"snr"`
+		} `json:"rx_metadata"`
+		Settings struct {
+			DataRate struct {
+				LoRa struct {
+					Bandwidth       uint32 `json:"bandwidth"`
+					SpreadingFactor uint32 `json:"spreading_factor"`
+				} `json:"lora"`
+			} `json:"data_rate"`
+			// uint64 values are strings in the stack's json
+			Frequency string `json:"frequency"`
+		} `json:"settings"`
+	} `json:"uplink_message"`
+}
+
+// DecodeTTSUplink decodes a The Things Stack v3 uplink event, other event types are an error
+func DecodeTTSUplink(data []byte) (*LoRaWANUplink, error) {
+	var event ttsUplinkEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("could not unmarshall tts event: %v", err)
+	}
+	up := event.UplinkMessage
+	if up == nil {
+		return nil, fmt.Errorf("tts event for %s is not an uplink", event.EndDeviceIDs.DeviceID)
+	}
+	if event.EndDeviceIDs.DevEUI == "" {
+		return nil, fmt.Errorf("tts uplink for %s has no dev_eui", event.EndDeviceIDs.DeviceID)
+	}
+
+	radio := RadioMetadata{
+		DevEUI:          event.EndDeviceIDs.DevEUI,
+		DevAddr:         event.EndDeviceIDs.DevAddr,
+		FPort:           up.FPort,
+		FCnt:            up.FCnt,
+		SpreadingFactor: up.Settings.DataRate.LoRa.SpreadingFactor,
+		Bandwidth:       up.Settings.DataRate.LoRa.Bandwidth,
+	}
+	if up.Settings.Frequency != "" {
+		frequency, err := strconv.ParseUint(up.Settings.Frequency, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tts frequency %q: %v", up.Settings.Frequency, err)
+		}
+		radio.Frequency = frequency
+	}
+	for _, rx := range up.RxMetadata {
+		radio.Gateways = append(radio.Gateways, GatewayMetadata{
+			GatewayID: rx.GatewayIDs.GatewayID,
+			RSSI:      rx.RSSI,
+			SNR:       rx.SNR,
+		})
+	}
+
+	received := up.ReceivedAt
+	if received.IsZero() {
+		received = event.ReceivedAt
+	}
+	return &LoRaWANUplink{
+		SimpleMessage: SimpleMessage{
+			BrokerDevice: BrokerDevice{Source: TheThingsStackSource, DeviceUID: NormalizeDevEUI(event.EndDeviceIDs.DevEUI)},
+			Payload:      up.FRMPayload,
+			Time:         received,
+		},
+		Radio: radio,
+	}, nil
+}
+
+type ttsDownlink struct {
+	FPort      uint32 `json:"f_port"`
+	FRMPayload []byte `json:"frm_payload"`
+	Priority   string `json:"priority"`
+	Confirmed  bool   `json:"confirmed,omitempty"`
+}
+
+// EncodeTTSDownlink gives the body for the stack's downlink push/replace webhook or MQTT topic
+func EncodeTTSDownlink(d DownlinkRequest) ([]byte, error) {
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Downlinks []ttsDownlink `json:"downlinks"`
+	}{
+		Downlinks: []ttsDownlink{{
+			FPort:      d.FPort,
+			FRMPayload: d.Payload,
+			Priority:   "NORMAL",
+			Confirmed:  d.Confirmed,
+		}},
+	})
+}
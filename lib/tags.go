@@ -0,0 +1,216 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TagAssignment records a DeviceUID serving a DeviceTag from From until To, a nil To is the current assignment
+type TagAssignment struct {
+	CompanyUID string     `firestore:"companyUID" json:"companyUID"`
+	DeviceTag  string     `firestore:"tag" json:"deviceTag"`
+	DeviceUID  string     `firestore:"uid" json:"uid"`
+	From       time.Time  `firestore:"from" json:"from"`
+	To         *time.Time `firestore:"to,omitempty" json:"to,omitempty"`
+}
+
+// Active reports whether the assignment covers t, From is inclusive and To exclusive
+func (a TagAssignment) Active(t time.Time) bool {
+	return !t.Before(a.From) && (a.To == nil || t.Before(*a.To))
+}
+
+// TagHistory keeps the assignments of each tag in a company
+type TagHistory interface {
+	// Record ends the tag's current assignment at a.From and makes a current, recording the UID already serving the
+	// tag is a no op
+	Record(ctx context.Context, a TagAssignment) error
+	// History lists a tag's assignments oldest first
+	History(ctx context.Context, companyUID, tag string) ([]TagAssignment, error)
+}
+
+// NextAssignments applies a to a tag's history for TagHistory implementations, returning the history unchanged
+// if a's device already holds the tag
+func NextAssignments(history []TagAssignment, a TagAssignment) ([]TagAssignment, error) {
+	if a.DeviceUID == "" || a.DeviceTag == "" {
+		return nil, fmt.Errorf("tag assignment needs a uid and tag")
+	}
+	if a.From.IsZero() {
+		return nil, fmt.Errorf("tag assignment needs a start time")
+	}
+	a.To = nil
+	if len(history) == 0 {
+		return []TagAssignment{a}, nil
+	}
+
+	last := history[len(history)-1]
+	if last.To == nil && last.DeviceUID == a.DeviceUID {
+		return history, nil
+	}
+	if !a.From.After(last.From) || (last.To != nil && a.From.Before(*last.To)) {
+		return nil, fmt.Errorf("tag %s assignment at %s overlaps the assignment to %s", a.DeviceTag, a.From, last.DeviceUID)
+	}
+	next := make([]TagAssignment, len(history), len(history)+1)
+	copy(next, history)
+	if last.To == nil {
+		to := a.From
+		next[len(next)-1].To = &to
+	}
+	return append(next, a), nil
+}
+
+// TagHolderAt finds the DeviceUID serving a tag at t, returning an error wrapping ErrDeviceNotFound if none was
+func TagHolderAt(ctx context.Context, h TagHistory, companyUID, tag string, t time.Time) (string, error) {
+	history, err := h.History(ctx, companyUID, tag)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range history {
+		if a.Active(t) {
+			return a.DeviceUID, nil
+		}
+	}
+	return "", fmt.Errorf("%w: tag %s at %s", ErrDeviceNotFound, tag, t)
+}
+
+// TagAssignmentsBetween gives the assignments serving a tag between from and to, clipped to the range, so readings
+// for a tag can be stitched together across hardware replacements by querying each DeviceUID over its interval
+func TagAssignmentsBetween(ctx context.Context, h TagHistory, companyUID, tag string, from, to time.Time) ([]TagAssignment, error) {
+	history, err := h.History(ctx, companyUID, tag)
+	if err != nil {
+		return nil, err
+	}
+	var clipped []TagAssignment
+	for _, a := range history {
+		if !a.From.Before(to) || (a.To != nil && !a.To.After(from)) {
+			continue
+		}
+		if a.From.Before(from) {
+			a.From = from
+		}
+		if a.To == nil || a.To.After(to) {
+			end := to
+			a.To = &end
+		}
+		clipped = append(clipped, a)
+	}
+	return clipped, nil
+}
+
+// SwapDevice moves tag from the device currently serving it to newUID at the given time.
+// The new device takes the old device's meta, keeping its own Firmware and lifecycle, and the old device keeps its
+// meta without the tag so its past readings still resolve. The old device's assignment must already be recorded in
+// the TagHistory, a tag with no history is refused rather than guessing when the old device took it.
+// The history is written before the registry and each write can be repeated, so a SwapDevice that fails part way
+// finishes when retried with the same arguments.
+func SwapDevice(ctx context.Context, registry DeviceRegistry, h TagHistory, companyUID, tag, newUID string, at time.Time) error {
+	history, err := h.History(ctx, companyUID, tag)
+	if err != nil {
+		return fmt.Errorf("could not get history of %s: %w", tag, err)
+	}
+	old, err := registry.GetDeviceByTag(ctx, companyUID, tag)
+	if errors.Is(err, ErrDeviceNotFound) && len(history) > 1 && history[len(history)-1].DeviceUID == newUID {
+		// an earlier attempt released the tag but failed before giving it to newUID
+		old, err = registry.GetDevice(ctx, history[len(history)-2].DeviceUID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not find device serving %s: %w", tag, err)
+	}
+	if old.DeviceUID == newUID {
+		return nil
+	}
+
+	current, err := registry.GetDevice(ctx, newUID)
+	if err != nil && !errors.Is(err, ErrDeviceNotFound) {
+		return fmt.Errorf("could not get device %s: %w", newUID, err)
+	}
+	replacement := copyDevice(old)
+	replacement.DeviceUID = newUID
+	if replacement.DeviceMeta == nil {
+		replacement.DeviceMeta = &DeviceMeta{CompanyUID: companyUID}
+	}
+	replacement.DeviceTag = tag
+	// the old device's state, e.g. faulty, belongs to the old hardware
+	replacement.Status, replacement.StatusHistory = "", nil
+	if err == nil && current.DeviceMeta != nil {
+		if current.DeviceTag != "" {
+			return fmt.Errorf("%w: %s already serves tag %s", ErrDeviceConflict, newUID, current.DeviceTag)
+		}
+		if current.Firmware != nil {
+			replacement.Firmware = current.Firmware
+		}
+		replacement.Status, replacement.StatusHistory = current.Status, current.StatusHistory
+	}
+
+	if err = checkTagHistory(history, old, tag, newUID); err != nil {
+		return err
+	}
+	if err = h.Record(ctx, TagAssignment{CompanyUID: companyUID, DeviceTag: tag, DeviceUID: newUID, From: at}); err != nil {
+		return fmt.Errorf("could not record tag %s for %s: %w", tag, newUID, err)
+	}
+	retired := copyDevice(old)
+	if retired.DeviceMeta != nil {
+		retired.DeviceTag = ""
+	}
+	if err = registry.UpsertDevice(ctx, retired); err != nil {
+		return fmt.Errorf("could not release tag %s from %s: %w", tag, old.DeviceUID, err)
+	}
+	if err = registry.UpsertDevice(ctx, replacement); err != nil {
+		return fmt.Errorf("could not give tag %s to %s: %w", tag, newUID, err)
+	}
+	return nil
+}
+
+// checkTagHistory makes sure the history shows old, or newUID on a retry, serving tag before the swap
+func checkTagHistory(history []TagAssignment, old *Device, tag, newUID string) error {
+	n := len(history)
+	if n == 0 {
+		return fmt.Errorf("tag %s has no history, record %s's assignment before swapping", tag, old.DeviceUID)
+	}
+	holder := history[n-1]
+	if holder.To == nil && holder.DeviceUID != old.DeviceUID && holder.DeviceUID != newUID {
+		return fmt.Errorf("%w: history has %s serving %s, not %s", ErrDeviceConflict, holder.DeviceUID, tag, old.DeviceUID)
+	}
+	return nil
+}
+
+// MemoryTagHistory is a TagHistory held in memory for tests and small tools
+type MemoryTagHistory struct {
+	mu      sync.RWMutex
+	history map[[2]string][]TagAssignment
+}
+
+func NewMemoryTagHistory() *MemoryTagHistory {
+	return &MemoryTagHistory{history: make(map[[2]string][]TagAssignment)}
+}
+
+func (h *MemoryTagHistory) Record(ctx context.Context, a TagAssignment) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := [2]string{a.CompanyUID, a.DeviceTag}
+	next, err := NextAssignments(h.history[key], a)
+	if err != nil {
+		return err
+	}
+	h.history[key] = next
+	return nil
+}
+
+func (h *MemoryTagHistory) History(ctx context.Context, companyUID, tag string) ([]TagAssignment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stored := h.history[[2]string{companyUID, tag}]
+	history := make([]TagAssignment, len(stored))
+	copy(history, stored)
+	return history, nil
+}
@@ -0,0 +1,209 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSwapDevice(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryDeviceRegistry()
+	history := NewMemoryTagHistory()
+	installed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	swapped := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	err := registry.UpsertDevice(ctx, &Device{DeviceUID: "ka4500", DeviceMeta: &DeviceMeta{
+		DeviceName: "cooler", DeviceTag: "cooler-4b", CompanyUID: "c1", LocationUID: "4b",
+		Firmware: &Firmware{FirmwareName: "ka", FirmwareVersion: "1.0"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = registry.UpsertDevice(ctx, &Device{DeviceUID: "ka4501", DeviceMeta: &DeviceMeta{
+		Firmware: &Firmware{FirmwareName: "ka", FirmwareVersion: "2.0"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = history.Record(ctx, TagAssignment{CompanyUID: "c1", DeviceTag: "cooler-4b", DeviceUID: "ka4500", From: installed}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = SwapDevice(ctx, registry, history, "c1", "cooler-4b", "ka4501", swapped); err != nil {
+		t.Fatalf("SwapDevice() error = %v", err)
+	}
+
+	d, err := registry.GetDeviceByTag(ctx, "c1", "cooler-4b")
+	if err != nil || d.DeviceUID != "ka4501" {
+		t.Fatalf("GetDeviceByTag() = %v, %v", d, err)
+	}
+	if d.LocationUID != "4b" || d.DeviceName != "cooler" || d.Firmware.FirmwareVersion != "2.0" {
+		t.Errorf("replacement meta = %+v", d.DeviceMeta)
+	}
+	if old, _ := registry.GetDevice(ctx, "ka4500"); old.DeviceTag != "" || old.LocationUID != "4b" {
+		t.Errorf("retired meta = %+v", old.DeviceMeta)
+	}
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		want    string
+		wantErr error
+	}{
+		{name: "before install", at: installed.Add(-time.Hour), wantErr: ErrDeviceNotFound},
+		{name: "original", at: installed, want: "ka4500"},
+		{name: "just before swap", at: swapped.Add(-time.Second), want: "ka4500"},
+		{name: "at swap", at: swapped, want: "ka4501"},
+		{name: "now", at: time.Now(), want: "ka4501"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TagHolderAt(ctx, history, "c1", "cooler-4b", tt.at)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TagHolderAt() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("TagHolderAt() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	from, to := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	intervals, err := TagAssignmentsBetween(ctx, history, "c1", "cooler-4b", from, to)
+	if err != nil || len(intervals) != 2 {
+		t.Fatalf("TagAssignmentsBetween() = %v, %v", intervals, err)
+	}
+	if !intervals[0].From.Equal(from) || !intervals[0].To.Equal(swapped) || !intervals[1].To.Equal(to) {
+		t.Errorf("TagAssignmentsBetween() = %+v", intervals)
+	}
+
+	if err = history.Record(ctx, TagAssignment{CompanyUID: "c1", DeviceTag: "cooler-4b", DeviceUID: "other", From: installed}); err == nil {
+		t.Errorf("Record() should refuse an assignment before the current one")
+	}
+}
+
+// failingRegistry fails the upsert numbered fail, counting from 1
+type failingRegistry struct {
+	*MemoryDeviceRegistry
+	upserts, fail int
+}
+
+func (r *failingRegistry) UpsertDevice(ctx context.Context, d *Device) error {
+	r.upserts++
+	if r.upserts == r.fail {
+		return errors.New("registry unavailable")
+	}
+	return r.MemoryDeviceRegistry.UpsertDevice(ctx, d)
+}
+
+func TestSwapDevice_Retry(t *testing.T) {
+	ctx := context.Background()
+	installed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	swapped := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, fail := range []int{2, 3} {
+		registry := &failingRegistry{MemoryDeviceRegistry: NewMemoryDeviceRegistry(), fail: fail}
+		history := NewMemoryTagHistory()
+		err := registry.UpsertDevice(ctx, &Device{DeviceUID: "ka4500", DeviceMeta: &DeviceMeta{DeviceTag: "cooler-4b", CompanyUID: "c1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = history.Record(ctx, TagAssignment{CompanyUID: "c1", DeviceTag: "cooler-4b", DeviceUID: "ka4500", From: installed}); err != nil {
+			t.Fatal(err)
+		}
+		if err = SwapDevice(ctx, registry, history, "c1", "cooler-4b", "ka4501", swapped); err == nil {
+			t.Fatalf("SwapDevice() with upsert %d failing should fail", fail)
+		}
+		if err = SwapDevice(ctx, registry, history, "c1", "cooler-4b", "ka4501", swapped); err != nil {
+			t.Fatalf("SwapDevice() retry after upsert %d failed error = %v", fail, err)
+		}
+		if d, err := registry.GetDeviceByTag(ctx, "c1", "cooler-4b"); err != nil || d.DeviceUID != "ka4501" {
+			t.Errorf("GetDeviceByTag() after retry = %v, %v", d, err)
+		}
+		// the original device's tenure survives the retry
+		if uid, err := TagHolderAt(ctx, history, "c1", "cooler-4b", installed); err != nil || uid != "ka4500" {
+			t.Errorf("TagHolderAt(installed) = %s, %v", uid, err)
+		}
+		if h, _ := history.History(ctx, "c1", "cooler-4b"); len(h) != 2 {
+			t.Errorf("History() = %+v", h)
+		}
+	}
+}
+
+func TestSwapDevice_NoHistory(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryDeviceRegistry()
+	history := NewMemoryTagHistory()
+	installed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := registry.UpsertDevice(ctx, &Device{DeviceUID: "ka4500", DeviceMeta: &DeviceMeta{
+		DeviceTag: "cooler-4b", CompanyUID: "c1",
+		StatusHistory: []LifecycleTransition{{To: Active, At: installed}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = SwapDevice(ctx, registry, history, "c1", "cooler-4b", "ka4501", time.Now()); err == nil {
+		t.Fatalf("SwapDevice() should refuse a tag with no history")
+	}
+	if d, err := registry.GetDeviceByTag(ctx, "c1", "cooler-4b"); err != nil || d.DeviceUID != "ka4500" {
+		t.Errorf("GetDeviceByTag() after refused swap = %v, %v", d, err)
+	}
+}
+
+func TestSwapDevice_Faulty(t *testing.T) {
+	ctx := context.Background()
+	registry := NewMemoryDeviceRegistry()
+	history := NewMemoryTagHistory()
+	installed := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	failed := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	err := registry.UpsertDevice(ctx, &Device{DeviceUID: "ka4500", DeviceMeta: &DeviceMeta{
+		DeviceTag: "cooler-4b", CompanyUID: "c1", Status: Faulty,
+		StatusHistory: []LifecycleTransition{{From: Active, To: Faulty, At: failed}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = history.Record(ctx, TagAssignment{CompanyUID: "c1", DeviceTag: "cooler-4b", DeviceUID: "ka4500", From: installed}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		newUID     string
+		current    *DeviceMeta
+		wantStatus LifecycleState
+		wantReport bool
+	}{
+		{name: "new device", newUID: "ka4501", wantReport: true},
+		{name: "commissioned device", newUID: "ka4502", current: &DeviceMeta{Status: Commissioned,
+			StatusHistory: []LifecycleTransition{{From: Provisioned, To: Commissioned, At: failed}}}, wantStatus: Commissioned},
+	}
+	at := failed
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.current != nil {
+				if err := registry.UpsertDevice(ctx, &Device{DeviceUID: tt.newUID, DeviceMeta: tt.current}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			at = at.Add(24 * time.Hour)
+			if err := SwapDevice(ctx, registry, history, "c1", "cooler-4b", tt.newUID, at); err != nil {
+				t.Fatalf("SwapDevice() error = %v", err)
+			}
+			d, err := registry.GetDevice(ctx, tt.newUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Status != tt.wantStatus || d.Status.Reporting() != tt.wantReport {
+				t.Errorf("replacement status = %q, want %q", d.Status, tt.wantStatus)
+			}
+			for _, transition := range d.StatusHistory {
+				if transition.To == Faulty {
+					t.Errorf("replacement took the old device's history %+v", d.StatusHistory)
+				}
+			}
+		})
+	}
+}
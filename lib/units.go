@@ -1,7 +1,311 @@
 package lib
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
 type Unit string
 
 const (
-	KWH Unit = "kWh"
+	WattHour            Unit = "Wh"
+	KilowattHour        Unit = "kWh"
+	MegawattHour        Unit = "MWh"
+	Watt                Unit = "W"
+	Kilowatt            Unit = "kW"
+	Megawatt            Unit = "MW"
+	VoltAmp             Unit = "VA"
+	KilovoltAmp         Unit = "kVA"
+	VoltAmpReactive     Unit = "var"
+	KilovoltAmpReactive Unit = "kvar"
+	Amp                 Unit = "A"
+	MilliAmp            Unit = "mA"
+	Volt                Unit = "V"
+	Kilovolt            Unit = "kV"
+
+	CubicMetre Unit = "m³"
+	Litre      Unit = "L"
+	DegC       Unit = "°C"
+	DegF       Unit = "°F"
+	Kelvin     Unit = "K"
+
+	CubicMetrePerHour Unit = "m³/h"
+	LitrePerSecond    Unit = "L/s"
+	LitrePerMinute    Unit = "L/min"
+)
+
+// KWH is the name KilowattHour had before the other units were added.
+//
+// Deprecated: use KilowattHour.
+const KWH = KilowattHour
+
+var (
+	ErrUnknownUnit = errors.New("unknown unit")
+	// ErrDimensionMismatch is returned when converting or combining quantities of different dimensions e.g. kWh and kW
+	ErrDimensionMismatch = errors.New("dimension mismatch")
+	// ErrOffsetUnit is returned when adding or subtracting in a unit with an Offset such as °C, where the result
+	// depends on the unit's zero, convert to a unit without one such as Kelvin first
+	ErrOffsetUnit = errors.New("unit has an offset")
 )
+
+type Dimension string
+
+const (
+	DimEnergy        Dimension = "energy"
+	DimPower         Dimension = "power"
+	DimApparentPower Dimension = "apparentPower"
+	DimReactivePower Dimension = "reactivePower"
+	DimCurrent       Dimension = "current"
+	DimVoltage       Dimension = "voltage"
+	DimVolume        Dimension = "volume"
+	DimTemperature   Dimension = "temperature"
+	DimFlow          Dimension = "flow"
+)
+
+// UnitInfo places a unit in its dimension, a value in the unit is value*Factor + Offset in the dimension's base unit
+type UnitInfo struct {
+	Unit      Unit      `json:"unit"`
+	Dimension Dimension `json:"dimension"`
+	Factor    float64   `json:"factor"`
+	Offset    float64   `json:"offset,omitempty"`
+}
+
+func (u UnitInfo) toBase(v float64) float64 {
+	return v*u.Factor + u.Offset
+}
+
+func (u UnitInfo) fromBase(v float64) float64 {
+	return (v - u.Offset) / u.Factor
+}
+
+// UnitRegistry knows the units quantities can be given in
+type UnitRegistry struct {
+	mu    sync.RWMutex
+	units map[Unit]UnitInfo
+}
+
+func NewUnitRegistry() *UnitRegistry {
+	return &UnitRegistry{units: make(map[Unit]UnitInfo)}
+}
+
+// DefaultUnits holds the units defined in this package, Quantity uses it for conversion and json
+var DefaultUnits = newDefaultUnits()
+
+func newDefaultUnits() *UnitRegistry {
+	r := NewUnitRegistry()
+	for _, u := range []UnitInfo{
+		{Unit: WattHour, Dimension: DimEnergy, Factor: 1},
+		{Unit: KilowattHour, Dimension: DimEnergy, Factor: 1e3},
+		{Unit: MegawattHour, Dimension: DimEnergy, Factor: 1e6},
+		{Unit: Watt, Dimension: DimPower, Factor: 1},
+		{Unit: Kilowatt, Dimension: DimPower, Factor: 1e3},
+		{Unit: Megawatt, Dimension: DimPower, Factor: 1e6},
+		{Unit: VoltAmp, Dimension: DimApparentPower, Factor: 1},
+		{Unit: KilovoltAmp, Dimension: DimApparentPower, Factor: 1e3},
+		{Unit: VoltAmpReactive, Dimension: DimReactivePower, Factor: 1},
+		{Unit: KilovoltAmpReactive, Dimension: DimReactivePower, Factor: 1e3},
+		{Unit: Amp, Dimension: DimCurrent, Factor: 1},
+		{Unit: MilliAmp, Dimension: DimCurrent, Factor: 1e-3},
+		{Unit: Volt, Dimension: DimVoltage, Factor: 1},
+		{Unit: Kilovolt, Dimension: DimVoltage, Factor: 1e3},
+		{Unit: Litre, Dimension: DimVolume, Factor: 1},
+		{Unit: CubicMetre, Dimension: DimVolume, Factor: 1e3},
+		{Unit: Kelvin, Dimension: DimTemperature, Factor: 1},
+		{Unit: DegC, Dimension: DimTemperature, Factor: 1, Offset: 273.15},
+		{Unit: DegF, Dimension: DimTemperature, Factor: 5.0 / 9, Offset: 273.15 - 32*5.0/9},
+		{Unit: LitrePerSecond, Dimension: DimFlow, Factor: 1},
+		{Unit: LitrePerMinute, Dimension: DimFlow, Factor: 1.0 / 60},
+		{Unit: CubicMetrePerHour, Dimension: DimFlow, Factor: 1e3 / 3600},
+	} {
+		if err := r.Register(u); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds a unit, a unit can't be moved to another dimension once registered
+func (r *UnitRegistry) Register(u UnitInfo) error {
+	if u.Unit == "" || u.Dimension == "" {
+		return fmt.Errorf("unit needs a name and dimension")
+	}
+	if u.Factor == 0 || math.IsNaN(u.Factor) || math.IsInf(u.Factor, 0) {
+		return fmt.Errorf("unit %s needs a finite non zero factor", u.Unit)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.units[u.Unit]; ok && existing.Dimension != u.Dimension {
+		return fmt.Errorf("unit %s is already a unit of %s", u.Unit, existing.Dimension)
+	}
+	r.units[u.Unit] = u
+	return nil
+}
+
+func (r *UnitRegistry) Lookup(u Unit) (UnitInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info, ok := r.units[u]
+	if !ok {
+		return UnitInfo{}, fmt.Errorf("%w: %q", ErrUnknownUnit, string(u))
+	}
+	return info, nil
+}
+
+// Units lists the units of a dimension by name, an empty dimension lists every unit
+func (r *UnitRegistry) Units(d Dimension) []UnitInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var units []UnitInfo
+	for _, u := range r.units {
+		if d == "" || u.Dimension == d {
+			units = append(units, u)
+		}
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].Unit < units[j].Unit
+	})
+	return units
+}
+
+// Convert changes q to the unit to, both units must be registered in the same dimension
+func (r *UnitRegistry) Convert(q Quantity, to Unit) (Quantity, error) {
+	from, err := r.Lookup(q.Unit)
+	if err != nil {
+		return Quantity{}, err
+	}
+	target, err := r.Lookup(to)
+	if err != nil {
+		return Quantity{}, err
+	}
+	if from.Dimension != target.Dimension {
+		return Quantity{}, fmt.Errorf("%w: %s is %s not %s", ErrDimensionMismatch, q.Unit, from.Dimension, target.Dimension)
+	}
+	if q.Unit == to {
+		return q, nil
+	}
+	return Quantity{Value: target.fromBase(from.toBase(q.Value)), Unit: to}, nil
+}
+
+// Dimension gives a unit's dimension from the DefaultUnits
+func (u Unit) Dimension() (Dimension, error) {
+	info, err := DefaultUnits.Lookup(u)
+	return info.Dimension, err
+}
+
+// Quantity is a value in a Unit, it serialises as {"value": 1.5, "unit": "kWh"} in json and as a value/unit record
+// in BigQuery
+type Quantity struct {
+	Value float64 `bigquery:"value" firestore:"value" json:"value"`
+	Unit  Unit    `bigquery:"unit" firestore:"unit" json:"unit"`
+}
+
+// ParseQuantity reads a value followed by its unit such as "12.5 kWh" or "3m³"
+func ParseQuantity(s string) (Quantity, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.' || r == '-' || r == '+' || r == 'e' || r == 'E')
+	})
+	if i <= 0 {
+		return Quantity{}, fmt.Errorf("quantity %q needs a value and unit", s)
+	}
+	// an exponent marker directly before the unit belongs to the unit
+	for i > 0 && (s[i-1] == 'e' || s[i-1] == 'E') {
+		i--
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return Quantity{}, fmt.Errorf("quantity %q: %w", s, err)
+	}
+	q := Quantity{Value: v, Unit: Unit(strings.TrimSpace(s[i:]))}
+	if _, err = DefaultUnits.Lookup(q.Unit); err != nil {
+		return Quantity{}, err
+	}
+	return q, nil
+}
+
+func (q Quantity) String() string {
+	return strconv.FormatFloat(q.Value, 'f', -1, 64) + " " + string(q.Unit)
+}
+
+func (q Quantity) Dimension() (Dimension, error) {
+	return q.Unit.Dimension()
+}
+
+// Convert changes q to the unit to using the DefaultUnits
+func (q Quantity) Convert(to Unit) (Quantity, error) {
+	return DefaultUnits.Convert(q, to)
+}
+
+// Add gives q + o in q's unit, units with an Offset are refused with ErrOffsetUnit
+func (q Quantity) Add(o Quantity) (Quantity, error) {
+	c, err := q.operand(o)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: q.Value + c.Value, Unit: q.Unit}, nil
+}
+
+// Sub gives q - o in q's unit, units with an Offset are refused with ErrOffsetUnit
+func (q Quantity) Sub(o Quantity) (Quantity, error) {
+	c, err := q.operand(o)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: q.Value - c.Value, Unit: q.Unit}, nil
+}
+
+// operand converts o to q's unit for Add and Sub
+func (q Quantity) operand(o Quantity) (Quantity, error) {
+	for _, u := range []Unit{q.Unit, o.Unit} {
+		info, err := DefaultUnits.Lookup(u)
+		if err != nil {
+			return Quantity{}, err
+		}
+		if info.Offset != 0 {
+			return Quantity{}, fmt.Errorf("%w: can't add or subtract in %s", ErrOffsetUnit, u)
+		}
+	}
+	return o.Convert(q.Unit)
+}
+
+func (q Quantity) Scale(f float64) Quantity {
+	return Quantity{Value: q.Value * f, Unit: q.Unit}
+}
+
+// Compare returns -1, 0 or 1 as q is below, equal to or above o
+func (q Quantity) Compare(o Quantity) (int, error) {
+	c, err := o.Convert(q.Unit)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case q.Value < c.Value:
+		return -1, nil
+	case q.Value > c.Value:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// UnmarshalJSON refuses units unknown to the DefaultUnits
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	type quantity Quantity
+	var decoded quantity
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if _, err := DefaultUnits.Lookup(decoded.Unit); err != nil {
+		return err
+	}
+	*q = Quantity(decoded)
+	return nil
+}
@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestQuantity_Convert(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       Quantity
+		to      Unit
+		want    float64
+		wantErr error
+	}{
+		{name: "Wh to kWh", q: Quantity{Value: 1500, Unit: WattHour}, to: KilowattHour, want: 1.5},
+		{name: "MWh to kWh", q: Quantity{Value: 2, Unit: MegawattHour}, to: KilowattHour, want: 2000},
+		{name: "kvar to var", q: Quantity{Value: 1.2, Unit: KilovoltAmpReactive}, to: VoltAmpReactive, want: 1200},
+		{name: "m³ to litres", q: Quantity{Value: 0.25, Unit: CubicMetre}, to: Litre, want: 250},
+		{name: "celsius to fahrenheit", q: Quantity{Value: 100, Unit: DegC}, to: DegF, want: 212},
+		{name: "fahrenheit to kelvin", q: Quantity{Value: 32, Unit: DegF}, to: Kelvin, want: 273.15},
+		{name: "m³/h to L/min", q: Quantity{Value: 6, Unit: CubicMetrePerHour}, to: LitrePerMinute, want: 100},
+		{name: "same unit", q: Quantity{Value: 3, Unit: Amp}, to: Amp, want: 3},
+		{name: "energy to power", q: Quantity{Value: 1, Unit: KilowattHour}, to: Kilowatt, wantErr: ErrDimensionMismatch},
+		{name: "VA to W", q: Quantity{Value: 1, Unit: VoltAmp}, to: Watt, wantErr: ErrDimensionMismatch},
+		{name: "unknown", q: Quantity{Value: 1, Unit: "BTU"}, to: KilowattHour, wantErr: ErrUnknownUnit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.q.Convert(tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Convert() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Unit != tt.to || math.Abs(got.Value-tt.want) > 1e-9) {
+				t.Errorf("Convert() = %v, want %v %s", got, tt.want, tt.to)
+			}
+		})
+	}
+}
+
+func TestQuantity_Arithmetic(t *testing.T) {
+	sum, err := Quantity{Value: 1, Unit: KilowattHour}.Add(Quantity{Value: 500, Unit: WattHour})
+	if err != nil || sum != (Quantity{Value: 1.5, Unit: KilowattHour}) {
+		t.Errorf("Add() = %v, %v", sum, err)
+	}
+	diff, err := Quantity{Value: 2, Unit: Kilowatt}.Sub(Quantity{Value: 500, Unit: Watt})
+	if err != nil || diff != (Quantity{Value: 1.5, Unit: Kilowatt}) {
+		t.Errorf("Sub() = %v, %v", diff, err)
+	}
+	if _, err = (Quantity{Value: 1, Unit: KilowattHour}).Add(Quantity{Value: 1, Unit: Kilowatt}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Add() of power to energy error = %v", err)
+	}
+	if _, err = (Quantity{Value: 20, Unit: DegC}).Add(Quantity{Value: 50, Unit: DegF}); !errors.Is(err, ErrOffsetUnit) {
+		t.Errorf("Add() of temperatures error = %v, want ErrOffsetUnit", err)
+	}
+	if _, err = (Quantity{Value: 300, Unit: Kelvin}).Sub(Quantity{Value: 20, Unit: DegC}); !errors.Is(err, ErrOffsetUnit) {
+		t.Errorf("Sub() of °C from K error = %v, want ErrOffsetUnit", err)
+	}
+	if sum, err = (Quantity{Value: 300, Unit: Kelvin}).Add(Quantity{Value: 5, Unit: Kelvin}); err != nil || sum.Value != 305 {
+		t.Errorf("Add() in Kelvin = %v, %v", sum, err)
+	}
+	if c, err := (Quantity{Value: 999, Unit: WattHour}).Compare(Quantity{Value: 1, Unit: KilowattHour}); err != nil || c != -1 {
+		t.Errorf("Compare() = %d, %v", c, err)
+	}
+	if s := (Quantity{Value: 2, Unit: KilowattHour}).Scale(0.5); s != (Quantity{Value: 1, Unit: KilowattHour}) {
+		t.Errorf("Scale() = %v", s)
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Quantity
+		wantErr bool
+	}{
+		{s: "12.5 kWh", want: Quantity{Value: 12.5, Unit: KilowattHour}},
+		{s: "3m³", want: Quantity{Value: 3, Unit: CubicMetre}},
+		{s: "-1.5e3 var", want: Quantity{Value: -1500, Unit: VoltAmpReactive}},
+		{s: "21 °C", want: Quantity{Value: 21, Unit: DegC}},
+		{s: "kWh", wantErr: true},
+		{s: "12", wantErr: true},
+		{s: "12 furlongs", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseQuantity(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseQuantity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseQuantity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantity_JSON(t *testing.T) {
+	b, err := json.Marshal(Quantity{Value: 1.5, Unit: KilowattHour})
+	if err != nil || string(b) != `{"value":1.5,"unit":"kWh"}` {
+		t.Errorf("Marshal() = %s, %v", b, err)
+	}
+	var q Quantity
+	if err = json.Unmarshal(b, &q); err != nil || q != (Quantity{Value: 1.5, Unit: KilowattHour}) {
+		t.Errorf("Unmarshal() = %v, %v", q, err)
+	}
+	if err = json.Unmarshal([]byte(`{"value":1,"unit":"BTU"}`), &q); !errors.Is(err, ErrUnknownUnit) {
+		t.Errorf("Unmarshal() error = %v, want ErrUnknownUnit", err)
+	}
+}
+
+func TestUnitRegistry_Register(t *testing.T) {
+	r := NewUnitRegistry()
+	if err := r.Register(UnitInfo{Unit: "BTU", Dimension: DimEnergy, Factor: 0.293071}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(UnitInfo{Unit: "BTU", Dimension: DimPower, Factor: 1}); err == nil {
+		t.Errorf("Register() should refuse moving a unit to another dimension")
+	}
+	if err := r.Register(UnitInfo{Unit: "x", Dimension: DimPower}); err == nil {
+		t.Errorf("Register() should refuse a zero factor")
+	}
+	if len(DefaultUnits.Units(DimEnergy)) != 3 {
+		t.Errorf("Units() = %v", DefaultUnits.Units(DimEnergy))
+	}
+}
@@ -29,8 +29,11 @@ func (s *Secrets) Close() error {
 }
 
 func (s *Secrets) SetSecret(secretID string, payload []byte) (*secretmanagerpb.Secret, error) {
-	ctx := context.Background()
+	return s.SetSecretContext(context.Background(), secretID, payload)
+}
 
+// SetSecretContext is SetSecret bounded by ctx
+func (s *Secrets) SetSecretContext(ctx context.Context, secretID string, payload []byte) (*secretmanagerpb.Secret, error) {
 	// Create the request to create the secret.
 	createSecretReq := &secretmanagerpb.CreateSecretRequest{
 		Parent:   fmt.Sprintf("projects/%s", s.projectID),
@@ -51,7 +54,7 @@ func (s *Secrets) SetSecret(secretID string, payload []byte) (*secretmanagerpb.S
 
 	if payload != nil {
 		var version *secretmanagerpb.SecretVersion
-		version, err = s.AddSecretVersion(secretID, payload)
+		version, err = s.AddSecretVersionContext(ctx, secretID, payload)
 		if version != nil {
 			log.Debug().Str("version", version.Name)
 		}
@@ -61,6 +64,11 @@ func (s *Secrets) SetSecret(secretID string, payload []byte) (*secretmanagerpb.S
 }
 
 func (s *Secrets) AddSecretVersion(secretName string, newPayload []byte) (*secretmanagerpb.SecretVersion, error) {
+	return s.AddSecretVersionContext(context.Background(), secretName, newPayload)
+}
+
+// AddSecretVersionContext is AddSecretVersion bounded by ctx
+func (s *Secrets) AddSecretVersionContext(ctx context.Context, secretName string, newPayload []byte) (*secretmanagerpb.SecretVersion, error) {
 	// Build the request.
 	req := &secretmanagerpb.AddSecretVersionRequest{
 		Parent: fmt.Sprintf("projects/%s/secrets/%s", s.projectID, secretName),
@@ -69,7 +77,6 @@ func (s *Secrets) AddSecretVersion(secretName string, newPayload []byte) (*secre
 		},
 	}
 
-	ctx := context.Background()
 	// Call the API.
 	return s.client.AddSecretVersion(ctx, req)
 }
@@ -77,9 +84,11 @@ func (s *Secrets) AddSecretVersion(secretName string, newPayload []byte) (*secre
 // GetSecret retrieve a secret with the given version name.
 // The version name must comply with naming convention - auto is 1, 2 etc
 func (s *Secrets) GetSecret(secret Secret) ([]byte, error) {
-	// Create the client.
-	ctx := context.Background()
+	return s.GetSecretContext(context.Background(), secret)
+}
 
+// GetSecretContext is GetSecret bounded by ctx
+func (s *Secrets) GetSecretContext(ctx context.Context, secret Secret) ([]byte, error) {
 	// Build the request.
 	accessRequest := &secretmanagerpb.AccessSecretVersionRequest{
 		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/%d", s.projectID, secret.Name, secret.Version),
@@ -94,6 +103,11 @@ func (s *Secrets) GetSecret(secret Secret) ([]byte, error) {
 }
 
 func (s *Secrets) UpdateSecret(secret Secret, labels map[string]string) error {
+	return s.UpdateSecretContext(context.Background(), secret, labels)
+}
+
+// UpdateSecretContext is UpdateSecret bounded by ctx
+func (s *Secrets) UpdateSecretContext(ctx context.Context, secret Secret, labels map[string]string) error {
 	// Build the request.
 	name := fmt.Sprintf("projects/%s/secrets/%s/versions/%d", s.projectID, secret.Name, secret.Version)
 	req := &secretmanagerpb.UpdateSecretRequest{
@@ -106,7 +120,6 @@ func (s *Secrets) UpdateSecret(secret Secret, labels map[string]string) error {
 		},
 	}
 
-	ctx := context.Background()
 	// Call the API.
 	result, err := s.client.UpdateSecret(ctx, req)
 	if err != nil {